@@ -5,6 +5,7 @@ import (
 
 	"api/pkg/auth"
 	"api/pkg/config"
+	"api/pkg/idempotency"
 	"api/pkg/order"
 	"api/pkg/product"
 	"github.com/gin-gonic/gin"
@@ -19,6 +20,13 @@ func main() {
 
 	r := gin.Default()
 
+	if err := r.SetTrustedProxies(c.TrustedProxies); err != nil {
+		log.Fatalln("Failed at trusted proxies", err)
+	}
+
+	r.Use(idempotency.ForwardKey)
+	r.Use(auth.ForwardClientInfo)
+
 	authSvc := *auth.RegisterRoutes(r, &c)
 	product.RegisterRoutes(r, &c, &authSvc)
 	order.RegisterRoutes(r, &c, &authSvc)
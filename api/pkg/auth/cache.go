@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	key       string
+	principal *Principal
+	expiresAt time.Time
+}
+
+// tokenCache is a bounded LRU of validated tokens whose entries also expire.
+type tokenCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func newTokenCache(size int, ttl time.Duration) *tokenCache {
+	return &tokenCache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *tokenCache) get(key string) (*Principal, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*cacheEntry)
+
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+
+	return entry.principal, true
+}
+
+// add caches the principal for the cache TTL, or until the token expires if
+// that comes first.
+func (c *tokenCache) add(key string, principal *Principal) {
+	if c.size <= 0 {
+		return
+	}
+
+	expiresAt := time.Now().Add(c.ttl)
+
+	if !principal.expiresAt.IsZero() && principal.expiresAt.Before(expiresAt) {
+		expiresAt = principal.expiresAt
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, principal: principal, expiresAt: expiresAt})
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// evict drops every entry whose principal matches.
+func (c *tokenCache) evict(match func(*Principal) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, el := range c.entries {
+		if match(el.Value.(*cacheEntry).principal) {
+			c.order.Remove(el)
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (c *tokenCache) clear() {
+	c.evict(func(*Principal) bool { return true })
+}
@@ -9,7 +9,8 @@ import (
 )
 
 type ServiceClient struct {
-	Client pb.AuthServiceClient
+	Client   pb.AuthServiceClient
+	Verifier *Verifier
 }
 
 func InitServiceClient(c *config.Config) pb.AuthServiceClient {
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	ClientIPMetadataKey  = "x-client-ip"
+	UserAgentMetadataKey = "x-client-user-agent"
+	UserIdMetadataKey    = "x-user-id"
+)
+
+// ForwardClientInfo passes the address and User-Agent of the end user on to
+// the backend services as gRPC metadata of the request context.
+func ForwardClientInfo(ctx *gin.Context) {
+	var pairs []string
+
+	if ip := clientIP(ctx); ip != "" {
+		pairs = append(pairs, ClientIPMetadataKey, ip)
+	}
+
+	if userAgent := ctx.Request.UserAgent(); userAgent != "" {
+		pairs = append(pairs, UserAgentMetadataKey, userAgent)
+	}
+
+	if len(pairs) > 0 {
+		ctx.Request = ctx.Request.WithContext(metadata.AppendToOutgoingContext(ctx.Request.Context(), pairs...))
+	}
+
+	ctx.Next()
+}
+
+// clientIP is the address the request came from. Only a proxy listed in
+// TRUSTED_PROXIES gets to name another address through X-Forwarded-For.
+func clientIP(ctx *gin.Context) string {
+	remoteIP, trusted := ctx.RemoteIP()
+
+	if remoteIP == nil {
+		return ""
+	}
+
+	if !trusted {
+		return remoteIP.String()
+	}
+
+	return ctx.ClientIP()
+}
@@ -1,19 +1,72 @@
 package auth
 
 import (
+	"strconv"
+	"strings"
+
 	"api/pkg/auth/routes"
 	"api/pkg/config"
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterRoutes(r *gin.Engine, c *config.Config) *ServiceClient {
+	client := InitServiceClient(c)
+
 	svc := &ServiceClient{
-		Client: InitServiceClient(c),
+		Client:   client,
+		Verifier: NewVerifier(client, c.AuthCacheSize, c.AuthCacheTTL),
 	}
 
+	go svc.Verifier.Watch()
+
+	a := InitAuthMiddleware(svc)
+
 	routes := r.Group("/auth")
 	routes.POST("/register", svc.Register)
 	routes.POST("/login", svc.Login)
+	routes.POST("/refresh", svc.Refresh)
+	routes.POST("/logout", svc.Logout)
+	routes.POST("/password/forgot", svc.RequestPasswordReset)
+	routes.POST("/password/reset", svc.ResetPassword)
+	routes.POST("/verify-email", svc.VerifyEmail)
+	routes.POST("/2fa/verify", svc.VerifySecondFactor)
+
+	// The account itself is only managed with the user's own session, never
+	// with an API key or a token issued to an OAuth client, whatever its scope.
+	accountRoutes := routes.Group("")
+	accountRoutes.Use(a.AuthRequired, a.TokenRequired)
+	accountRoutes.POST("/verification", svc.SendVerification)
+	accountRoutes.POST("/2fa/enroll", svc.EnrollTOTP)
+	accountRoutes.POST("/2fa/confirm", svc.ConfirmTOTP)
+	accountRoutes.POST("/2fa/disable", svc.DisableTOTP)
+	accountRoutes.GET("/me", svc.GetMe)
+	accountRoutes.PATCH("/me", svc.UpdateMe)
+	accountRoutes.PUT("/password", svc.ChangePassword)
+	accountRoutes.PUT("/email", svc.ChangeEmail)
+	accountRoutes.POST("/api-keys", svc.CreateAPIKey)
+	accountRoutes.GET("/api-keys", svc.ListAPIKeys)
+	accountRoutes.DELETE("/api-keys/:id", svc.RevokeAPIKey)
+
+	r.GET("/.well-known/jwks.json", svc.JWKS)
+
+	oauthRoutes := r.Group("/oauth2")
+	oauthRoutes.POST("/clients", a.AuthRequired, a.TokenRequired, svc.RegisterOAuthClient)
+	oauthRoutes.DELETE("/clients/:client_id", a.AuthRequired, a.TokenRequired, svc.DeleteOAuthClient)
+	oauthRoutes.GET("/authorize", a.AuthRequired, a.TokenRequired, svc.Authorize)
+	oauthRoutes.POST("/token", svc.Token)
+	oauthRoutes.POST("/introspect", svc.Introspect)
+	oauthRoutes.POST("/revoke", svc.Revoke)
+
+	adminRoutes := r.Group("/admin")
+	adminRoutes.Use(a.AuthRequired, a.RequirePermission(PermissionUserManage))
+	adminRoutes.GET("/users", svc.ListUsers)
+	adminRoutes.DELETE("/users/:id", svc.DeleteUser)
+	adminRoutes.POST("/users/:id/disable", svc.DisableUser)
+	adminRoutes.POST("/users/:id/enable", svc.EnableUser)
+	adminRoutes.PUT("/users/:id/roles", svc.AssignRoles)
+	adminRoutes.POST("/users/:id/unlock", svc.UnlockUser)
+	adminRoutes.POST("/ips/:ip/unlock", svc.UnlockIP)
+	adminRoutes.GET("/auth-events", svc.ListAuthEvents)
 
 	return svc
 }
@@ -25,3 +78,162 @@ func (svc *ServiceClient) Register(ctx *gin.Context) {
 func (svc *ServiceClient) Login(ctx *gin.Context) {
 	routes.Login(ctx, svc.Client)
 }
+
+func (svc *ServiceClient) Refresh(ctx *gin.Context) {
+	routes.Refresh(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) Logout(ctx *gin.Context) {
+	routes.Logout(ctx, svc.Client)
+	svc.Verifier.Revoke(strings.TrimPrefix(ctx.Request.Header.Get("authorization"), "Bearer "))
+}
+
+func (svc *ServiceClient) RequestPasswordReset(ctx *gin.Context) {
+	routes.RequestPasswordReset(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) ResetPassword(ctx *gin.Context) {
+	routes.ResetPassword(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) SendVerification(ctx *gin.Context) {
+	routes.SendVerification(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) VerifyEmail(ctx *gin.Context) {
+	routes.VerifyEmail(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) EnrollTOTP(ctx *gin.Context) {
+	routes.EnrollTOTP(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) ConfirmTOTP(ctx *gin.Context) {
+	routes.ConfirmTOTP(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) DisableTOTP(ctx *gin.Context) {
+	routes.DisableTOTP(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) VerifySecondFactor(ctx *gin.Context) {
+	routes.VerifySecondFactor(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) GetMe(ctx *gin.Context) {
+	routes.GetMe(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) UpdateMe(ctx *gin.Context) {
+	routes.UpdateMe(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) ChangePassword(ctx *gin.Context) {
+	routes.ChangePassword(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) ChangeEmail(ctx *gin.Context) {
+	routes.ChangeEmail(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) CreateAPIKey(ctx *gin.Context) {
+	routes.CreateAPIKey(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) ListAPIKeys(ctx *gin.Context) {
+	routes.ListAPIKeys(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) RevokeAPIKey(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+	routes.RevokeAPIKey(ctx, svc.Client, id)
+}
+
+func (svc *ServiceClient) ListUsers(ctx *gin.Context) {
+	routes.ListUsers(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) DisableUser(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	routes.DisableUser(ctx, svc.Client, id)
+}
+
+func (svc *ServiceClient) EnableUser(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	routes.EnableUser(ctx, svc.Client, id)
+}
+
+func (svc *ServiceClient) DeleteUser(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	routes.DeleteUser(ctx, svc.Client, id)
+}
+
+func (svc *ServiceClient) RegisterOAuthClient(ctx *gin.Context) {
+	routes.RegisterOAuthClient(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) DeleteOAuthClient(ctx *gin.Context) {
+	routes.DeleteOAuthClient(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) Authorize(ctx *gin.Context) {
+	routes.Authorize(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) Token(ctx *gin.Context) {
+	routes.Token(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) Introspect(ctx *gin.Context) {
+	routes.Introspect(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) Revoke(ctx *gin.Context) {
+	routes.Revoke(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) JWKS(ctx *gin.Context) {
+	routes.JWKS(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) AssignRoles(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	routes.AssignRoles(ctx, svc.Client, id)
+}
+
+func (svc *ServiceClient) UnlockUser(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	routes.UnlockUser(ctx, svc.Client, id)
+}
+
+func (svc *ServiceClient) UnlockIP(ctx *gin.Context) {
+	routes.UnlockIP(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) ListAuthEvents(ctx *gin.Context) {
+	routes.ListAuthEvents(ctx, svc.Client)
+}
@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"net/http"
+
+	"api/pkg/auth/pb"
+	"github.com/gin-gonic/gin"
+)
+
+type CreateAPIKeyRequestBody struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresIn int64    `json:"expiresIn"`
+}
+
+func CreateAPIKey(ctx *gin.Context, c pb.AuthServiceClient) {
+	b := CreateAPIKeyRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	res, err := c.CreateAPIKey(ctx.Request.Context(), &pb.CreateAPIKeyRequest{
+		UserId:    userId.(int64),
+		Name:      b.Name,
+		Scopes:    b.Scopes,
+		ExpiresIn: b.ExpiresIn,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+func ListAPIKeys(ctx *gin.Context, c pb.AuthServiceClient) {
+	userId, _ := ctx.Get("userId")
+
+	res, err := c.ListAPIKeys(ctx.Request.Context(), &pb.ListAPIKeysRequest{
+		UserId: userId.(int64),
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+func RevokeAPIKey(ctx *gin.Context, c pb.AuthServiceClient, id int64) {
+	userId, _ := ctx.Get("userId")
+
+	res, err := c.RevokeAPIKey(ctx.Request.Context(), &pb.RevokeAPIKeyRequest{
+		UserId: userId.(int64),
+		Id:     id,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
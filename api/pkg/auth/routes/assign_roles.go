@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"net/http"
+
+	"api/pkg/auth/pb"
+	"github.com/gin-gonic/gin"
+)
+
+type AssignRolesRequestBody struct {
+	Roles []string `json:"roles"`
+}
+
+func AssignRoles(ctx *gin.Context, c pb.AuthServiceClient, id int64) {
+	b := AssignRolesRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := c.AssignRoles(ctx.Request.Context(), &pb.AssignRolesRequest{
+		UserId: id,
+		Roles:  b.Roles,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
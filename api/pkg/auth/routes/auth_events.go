@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"api/pkg/auth/pb"
+	"github.com/gin-gonic/gin"
+)
+
+func ListAuthEvents(ctx *gin.Context, c pb.AuthServiceClient) {
+	req := pb.ListAuthEventsRequest{
+		Type:      ctx.Query("type"),
+		Since:     ctx.Query("since"),
+		Until:     ctx.Query("until"),
+		PageToken: ctx.Query("page_token"),
+	}
+	req.UserId, _ = strconv.ParseInt(ctx.Query("user_id"), 10, 64)
+	req.ActorId, _ = strconv.ParseInt(ctx.Query("actor_id"), 10, 64)
+	req.PageSize, _ = strconv.ParseInt(ctx.Query("page_size"), 10, 64)
+
+	res, err := c.ListAuthEvents(ctx.Request.Context(), &req)
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"net/http"
+
+	"api/pkg/auth/pb"
+	"github.com/gin-gonic/gin"
+)
+
+func JWKS(ctx *gin.Context, c pb.AuthServiceClient) {
+	res, err := c.GetJWKS(ctx.Request.Context(), &pb.GetJWKSRequest{})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	if res.Status != http.StatusOK {
+		ctx.JSON(int(res.Status), &res)
+		return
+	}
+
+	// Keys only change on rotation, so verifiers may cache them briefly.
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, gin.H{"keys": res.Keys})
+}
@@ -1,7 +1,6 @@
 package routes
 
 import (
-	"context"
 	"net/http"
 
 	"api/pkg/auth/pb"
@@ -21,7 +20,7 @@ func Login(ctx *gin.Context, c pb.AuthServiceClient) {
 		return
 	}
 
-	res, err := c.Login(context.Background(), &pb.LoginRequest{
+	res, err := c.Login(ctx.Request.Context(), &pb.LoginRequest{
 		Email:    b.Email,
 		Password: b.Password,
 	})
@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"api/pkg/auth/pb"
+	"github.com/gin-gonic/gin"
+)
+
+type LogoutRequestBody struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// Logout revokes the bearer access token and, when given, the session behind
+// the refresh token.
+func Logout(ctx *gin.Context, c pb.AuthServiceClient) {
+	b := LogoutRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	token := strings.TrimPrefix(ctx.Request.Header.Get("authorization"), "Bearer ")
+
+	res, err := c.Logout(context.Background(), &pb.LogoutRequest{
+		Token:        token,
+		RefreshToken: b.RefreshToken,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
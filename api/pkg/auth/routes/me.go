@@ -0,0 +1,108 @@
+package routes
+
+import (
+	"net/http"
+
+	"api/pkg/auth/pb"
+	"github.com/gin-gonic/gin"
+)
+
+func GetMe(ctx *gin.Context, c pb.AuthServiceClient) {
+	userId, _ := ctx.Get("userId")
+
+	res, err := c.GetMe(ctx.Request.Context(), &pb.GetMeRequest{
+		UserId: userId.(int64),
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+type UpdateMeRequestBody struct {
+	Name string `json:"name"`
+}
+
+func UpdateMe(ctx *gin.Context, c pb.AuthServiceClient) {
+	b := UpdateMeRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	res, err := c.UpdateMe(ctx.Request.Context(), &pb.UpdateMeRequest{
+		UserId: userId.(int64),
+		Name:   b.Name,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+type ChangePasswordRequestBody struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+func ChangePassword(ctx *gin.Context, c pb.AuthServiceClient) {
+	b := ChangePasswordRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	res, err := c.ChangePassword(ctx.Request.Context(), &pb.ChangePasswordRequest{
+		UserId:          userId.(int64),
+		CurrentPassword: b.CurrentPassword,
+		NewPassword:     b.NewPassword,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+type ChangeEmailRequestBody struct {
+	Password string `json:"password"`
+	Email    string `json:"email"`
+}
+
+func ChangeEmail(ctx *gin.Context, c pb.AuthServiceClient) {
+	b := ChangeEmailRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	res, err := c.ChangeEmail(ctx.Request.Context(), &pb.ChangeEmailRequest{
+		UserId:   userId.(int64),
+		Password: b.Password,
+		Email:    b.Email,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
@@ -0,0 +1,230 @@
+package routes
+
+import (
+	"net/http"
+	"net/url"
+
+	"api/pkg/auth/pb"
+	"github.com/gin-gonic/gin"
+)
+
+type RegisterOAuthClientRequestBody struct {
+	Name         string   `json:"name"`
+	RedirectUris []string `json:"redirectUris"`
+	Scopes       []string `json:"scopes"`
+	Confidential bool     `json:"confidential"`
+}
+
+func RegisterOAuthClient(ctx *gin.Context, c pb.AuthServiceClient) {
+	b := RegisterOAuthClientRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	res, err := c.RegisterOAuthClient(ctx.Request.Context(), &pb.RegisterOAuthClientRequest{
+		UserId:       userId.(int64),
+		Name:         b.Name,
+		RedirectUris: b.RedirectUris,
+		Scopes:       b.Scopes,
+		Confidential: b.Confidential,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+func DeleteOAuthClient(ctx *gin.Context, c pb.AuthServiceClient) {
+	userId, _ := ctx.Get("userId")
+
+	res, err := c.DeleteOAuthClient(ctx.Request.Context(), &pb.DeleteOAuthClientRequest{
+		UserId:   userId.(int64),
+		ClientId: ctx.Param("client_id"),
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+// Authorize takes the request of RFC 6749 4.1.1 on behalf of the signed-in
+// user, who is expected to have approved the client already.
+//
+// The gateway has no consent page and no session cookie: the user is only
+// recognised by a Bearer access token, so a browser sent here straight from a
+// client's site gets 401. The endpoint is meant to be called by the
+// first-party frontend, which must itself show the user the client and the
+// requested scopes and only call it once the user has approved them. Any
+// code it gets back is issued without asking again.
+func Authorize(ctx *gin.Context, c pb.AuthServiceClient) {
+	userId, _ := ctx.Get("userId")
+
+	res, err := c.OAuthAuthorize(ctx.Request.Context(), &pb.OAuthAuthorizeRequest{
+		UserId:              userId.(int64),
+		ClientId:            ctx.Query("client_id"),
+		RedirectUri:         ctx.Query("redirect_uri"),
+		ResponseType:        ctx.Query("response_type"),
+		Scope:               ctx.Query("scope"),
+		State:               ctx.Query("state"),
+		CodeChallenge:       ctx.Query("code_challenge"),
+		CodeChallengeMethod: ctx.Query("code_challenge_method"),
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	if res.Status == http.StatusFound {
+		ctx.Redirect(http.StatusFound, res.RedirectUri)
+		return
+	}
+
+	oauthError(ctx, int(res.Status), res.Error, res.ErrorDescription)
+}
+
+// Token is the token endpoint of RFC 6749 section 3.2.
+func Token(ctx *gin.Context, c pb.AuthServiceClient) {
+	clientId, clientSecret := clientCredentials(ctx)
+
+	res, err := c.OAuthToken(ctx.Request.Context(), &pb.OAuthTokenRequest{
+		GrantType:    ctx.PostForm("grant_type"),
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		Code:         ctx.PostForm("code"),
+		RedirectUri:  ctx.PostForm("redirect_uri"),
+		CodeVerifier: ctx.PostForm("code_verifier"),
+		RefreshToken: ctx.PostForm("refresh_token"),
+		Scope:        ctx.PostForm("scope"),
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Pragma", "no-cache")
+
+	if res.Status != http.StatusOK {
+		oauthError(ctx, int(res.Status), res.Error, res.ErrorDescription)
+		return
+	}
+
+	body := gin.H{
+		"access_token": res.AccessToken,
+		"token_type":   res.TokenType,
+		"expires_in":   res.ExpiresIn,
+		"scope":        res.Scope,
+	}
+
+	if res.RefreshToken != "" {
+		body["refresh_token"] = res.RefreshToken
+	}
+
+	ctx.JSON(http.StatusOK, body)
+}
+
+// Introspect implements RFC 7662.
+func Introspect(ctx *gin.Context, c pb.AuthServiceClient) {
+	clientId, clientSecret := clientCredentials(ctx)
+
+	res, err := c.OAuthIntrospect(ctx.Request.Context(), &pb.OAuthIntrospectRequest{
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		Token:        ctx.PostForm("token"),
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	if res.Status != http.StatusOK {
+		oauthError(ctx, int(res.Status), res.Error, res.ErrorDescription)
+		return
+	}
+
+	if !res.Active {
+		ctx.JSON(http.StatusOK, gin.H{"active": false})
+		return
+	}
+
+	body := gin.H{
+		"active":    true,
+		"scope":     res.Scope,
+		"client_id": res.ClientId,
+		"sub":       res.Sub,
+		"exp":       res.Exp,
+		"iat":       res.Iat,
+	}
+
+	if res.TokenType != "" {
+		body["token_type"] = res.TokenType
+	}
+
+	ctx.JSON(http.StatusOK, body)
+}
+
+// Revoke implements RFC 7009.
+func Revoke(ctx *gin.Context, c pb.AuthServiceClient) {
+	clientId, clientSecret := clientCredentials(ctx)
+
+	res, err := c.OAuthRevoke(ctx.Request.Context(), &pb.OAuthRevokeRequest{
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		Token:        ctx.PostForm("token"),
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	if res.Status != http.StatusOK {
+		oauthError(ctx, int(res.Status), res.Error, res.ErrorDescription)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// clientCredentials reads HTTP Basic authentication, falling back to the
+// form parameters (RFC 6749 2.3.1).
+func clientCredentials(ctx *gin.Context) (string, string) {
+	if id, secret, ok := ctx.Request.BasicAuth(); ok {
+		// Both parts are form-encoded before being put in the header.
+		if unescaped, err := url.QueryUnescape(id); err == nil {
+			id = unescaped
+		}
+
+		if unescaped, err := url.QueryUnescape(secret); err == nil {
+			secret = unescaped
+		}
+
+		return id, secret
+	}
+
+	return ctx.PostForm("client_id"), ctx.PostForm("client_secret")
+}
+
+func oauthError(ctx *gin.Context, status int, code string, description string) {
+	if status == http.StatusUnauthorized {
+		ctx.Header("WWW-Authenticate", `Basic realm="oauth2"`)
+	}
+
+	ctx.JSON(status, gin.H{
+		"error":             code,
+		"error_description": description,
+	})
+}
@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"net/http"
+
+	"api/pkg/auth/pb"
+	"github.com/gin-gonic/gin"
+)
+
+type RequestPasswordResetRequestBody struct {
+	Email string `json:"email"`
+}
+
+func RequestPasswordReset(ctx *gin.Context, c pb.AuthServiceClient) {
+	b := RequestPasswordResetRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := c.RequestPasswordReset(ctx.Request.Context(), &pb.RequestPasswordResetRequest{
+		Email: b.Email,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+type ResetPasswordRequestBody struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func ResetPassword(ctx *gin.Context, c pb.AuthServiceClient) {
+	b := ResetPasswordRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := c.ResetPassword(ctx.Request.Context(), &pb.ResetPasswordRequest{
+		Token:    b.Token,
+		Password: b.Password,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+
+	"api/pkg/auth/pb"
+	"github.com/gin-gonic/gin"
+)
+
+type RefreshRequestBody struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+func Refresh(ctx *gin.Context, c pb.AuthServiceClient) {
+	b := RefreshRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := c.Refresh(context.Background(), &pb.RefreshRequest{
+		RefreshToken: b.RefreshToken,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
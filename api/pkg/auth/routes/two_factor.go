@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"net/http"
+
+	"api/pkg/auth/pb"
+	"github.com/gin-gonic/gin"
+)
+
+type TwoFactorCodeRequestBody struct {
+	Code string `json:"code"`
+}
+
+type EnrollTOTPRequestBody struct {
+	Password string `json:"password"`
+}
+
+func EnrollTOTP(ctx *gin.Context, c pb.AuthServiceClient) {
+	b := EnrollTOTPRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	res, err := c.EnrollTOTP(ctx.Request.Context(), &pb.EnrollTOTPRequest{
+		UserId:   userId.(int64),
+		Password: b.Password,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+func ConfirmTOTP(ctx *gin.Context, c pb.AuthServiceClient) {
+	b := TwoFactorCodeRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	res, err := c.ConfirmTOTP(ctx.Request.Context(), &pb.ConfirmTOTPRequest{
+		UserId: userId.(int64),
+		Code:   b.Code,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+func DisableTOTP(ctx *gin.Context, c pb.AuthServiceClient) {
+	b := TwoFactorCodeRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	res, err := c.DisableTOTP(ctx.Request.Context(), &pb.DisableTOTPRequest{
+		UserId: userId.(int64),
+		Code:   b.Code,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+type VerifySecondFactorRequestBody struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"`
+}
+
+func VerifySecondFactor(ctx *gin.Context, c pb.AuthServiceClient) {
+	b := VerifySecondFactorRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := c.VerifySecondFactor(ctx.Request.Context(), &pb.VerifySecondFactorRequest{
+		ChallengeToken: b.ChallengeToken,
+		Code:           b.Code,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
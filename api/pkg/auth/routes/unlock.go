@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"net/http"
+
+	"api/pkg/auth/pb"
+	"github.com/gin-gonic/gin"
+)
+
+func UnlockUser(ctx *gin.Context, c pb.AuthServiceClient, id int64) {
+	unlock(ctx, c, &pb.UnlockRequest{UserId: id})
+}
+
+func UnlockIP(ctx *gin.Context, c pb.AuthServiceClient) {
+	unlock(ctx, c, &pb.UnlockRequest{Ip: ctx.Param("ip")})
+}
+
+func unlock(ctx *gin.Context, c pb.AuthServiceClient, req *pb.UnlockRequest) {
+	res, err := c.Unlock(ctx.Request.Context(), req)
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
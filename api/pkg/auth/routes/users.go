@@ -0,0 +1,67 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"api/pkg/auth/pb"
+	"github.com/gin-gonic/gin"
+)
+
+func ListUsers(ctx *gin.Context, c pb.AuthServiceClient) {
+	pageSize, _ := strconv.ParseInt(ctx.Query("page_size"), 10, 64)
+	includeDisabled, _ := strconv.ParseBool(ctx.Query("include_disabled"))
+
+	res, err := c.ListUsers(ctx.Request.Context(), &pb.ListUsersRequest{
+		Query:           ctx.Query("q"),
+		IncludeDisabled: includeDisabled,
+		PageSize:        pageSize,
+		PageToken:       ctx.Query("page_token"),
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+func DisableUser(ctx *gin.Context, c pb.AuthServiceClient, id int64) {
+	res, err := c.DisableUser(ctx.Request.Context(), &pb.DisableUserRequest{
+		UserId: id,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+func EnableUser(ctx *gin.Context, c pb.AuthServiceClient, id int64) {
+	res, err := c.EnableUser(ctx.Request.Context(), &pb.EnableUserRequest{
+		UserId: id,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+func DeleteUser(ctx *gin.Context, c pb.AuthServiceClient, id int64) {
+	res, err := c.DeleteUser(ctx.Request.Context(), &pb.DeleteUserRequest{
+		UserId: id,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
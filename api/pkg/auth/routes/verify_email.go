@@ -0,0 +1,47 @@
+package routes
+
+import (
+	"net/http"
+
+	"api/pkg/auth/pb"
+	"github.com/gin-gonic/gin"
+)
+
+func SendVerification(ctx *gin.Context, c pb.AuthServiceClient) {
+	userId, _ := ctx.Get("userId")
+
+	res, err := c.SendVerification(ctx.Request.Context(), &pb.SendVerificationRequest{
+		UserId: userId.(int64),
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+type VerifyEmailRequestBody struct {
+	Token string `json:"token"`
+}
+
+func VerifyEmail(ctx *gin.Context, c pb.AuthServiceClient) {
+	b := VerifyEmailRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := c.VerifyEmail(ctx.Request.Context(), &pb.VerifyEmailRequest{
+		Token: b.Token,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
@@ -0,0 +1,442 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"api/pkg/auth/pb"
+	"github.com/golang-jwt/jwt"
+)
+
+// Principal is what the gateway knows about the caller of a request.
+type Principal struct {
+	UserId      int64
+	Roles       []string
+	Permissions []string
+	// APIKeyId is set when the caller authenticated with an API key, and
+	// ClientId when with an OAuth access token.
+	APIKeyId int64
+	ClientId string
+
+	jti       string
+	issuedAt  time.Time
+	expiresAt time.Time
+}
+
+type tokenClaims struct {
+	jwt.StandardClaims
+	Id          int64
+	Email       string
+	Roles       []string
+	Permissions []string
+}
+
+// Valid only checks expiry. Gateway and auth service clocks may disagree by
+// a little, which must not reject a token issued a moment ago.
+func (c *tokenClaims) Valid() error {
+	if c.ExpiresAt != 0 && time.Now().Unix() > c.ExpiresAt {
+		return errors.New("JWT is expired")
+	}
+
+	return nil
+}
+
+var errRemoteRequired = errors.New("token must be validated by the auth service")
+
+// oauthAccessTokenPrefix marks the opaque access tokens issued through
+// /oauth2/token, which only the auth service can check.
+const oauthAccessTokenPrefix = "oat_"
+
+const (
+	// jwksRefreshInterval limits how often an unknown kid triggers a refetch.
+	jwksRefreshInterval = 30 * time.Second
+	// userChangeRetention is longer than any access token lives.
+	userChangeRetention = 24 * time.Hour
+	watchRetryDelay     = 5 * time.Second
+)
+
+// Verifier checks tokens against the auth service's published keys instead of
+// calling Validate for every request. It only trusts its own checks while it
+// is subscribed to the auth service's revocation feed; otherwise, and for
+// users whose roles changed after their token was issued, it falls back to
+// Validate. Results of either path are cached.
+type Verifier struct {
+	client pb.AuthServiceClient
+	cache  *tokenCache
+
+	mu          sync.RWMutex
+	keys        map[string]*verificationKey
+	keysFetched time.Time
+	watching    bool
+	revoked     map[string]time.Time
+	changed     map[int64]time.Time
+}
+
+type verificationKey struct {
+	alg    string
+	public interface{}
+}
+
+func NewVerifier(client pb.AuthServiceClient, cacheSize int, cacheTTL time.Duration) *Verifier {
+	return &Verifier{
+		client:  client,
+		cache:   newTokenCache(cacheSize, cacheTTL),
+		keys:    make(map[string]*verificationKey),
+		revoked: make(map[string]time.Time),
+		changed: make(map[int64]time.Time),
+	}
+}
+
+func (v *Verifier) Verify(ctx context.Context, token string) (*Principal, error) {
+	key := cacheKey(token)
+
+	if principal, ok := v.cache.get(key); ok {
+		return principal, nil
+	}
+
+	var principal *Principal
+	var err error
+
+	if strings.HasPrefix(token, oauthAccessTokenPrefix) {
+		principal, err = v.validateRemotely(ctx, token)
+	} else {
+		principal, err = v.verifyLocally(token)
+
+		if errors.Is(err, errRemoteRequired) {
+			principal, err = v.validateRemotely(ctx, token)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	v.cache.add(key, principal)
+
+	return principal, nil
+}
+
+// VerifyAPIKey always asks the auth service, since keys are opaque, but
+// caches the answer like Verify does.
+func (v *Verifier) VerifyAPIKey(ctx context.Context, key string) (*Principal, error) {
+	cached := cacheKey(key)
+
+	if principal, ok := v.cache.get(cached); ok {
+		return principal, nil
+	}
+
+	res, err := v.client.Validate(ctx, &pb.ValidateRequest{
+		ApiKey: key,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Status != http.StatusOK {
+		return nil, errors.New(res.Error)
+	}
+
+	principal := &Principal{
+		UserId:      res.UserId,
+		Permissions: res.Permissions,
+		APIKeyId:    res.ApiKeyId,
+		jti:         fmt.Sprintf("api-key:%d", res.ApiKeyId),
+	}
+
+	v.cache.add(cached, principal)
+
+	return principal, nil
+}
+
+// Revoke stops accepting a token straight away, without waiting for the
+// revocation notice from the auth service.
+func (v *Verifier) Revoke(token string) {
+	claims := &tokenClaims{}
+
+	if _, _, err := new(jwt.Parser).ParseUnverified(token, claims); err != nil {
+		return
+	}
+
+	v.revoke(claims.StandardClaims.Id, time.Unix(claims.ExpiresAt, 0))
+}
+
+func (v *Verifier) verifyLocally(token string) (*Principal, error) {
+	v.mu.RLock()
+	watching := v.watching
+	v.mu.RUnlock()
+
+	if !watching {
+		return nil, errRemoteRequired
+	}
+
+	claims := &tokenClaims{}
+
+	if _, err := jwt.ParseWithClaims(token, claims, v.keyFunc); err != nil {
+		return nil, err
+	}
+
+	principal := &Principal{
+		UserId:      claims.Id,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+		jti:         claims.StandardClaims.Id,
+		issuedAt:    time.Unix(claims.IssuedAt, 0),
+		expiresAt:   time.Unix(claims.ExpiresAt, 0),
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if _, ok := v.revoked[principal.jti]; ok {
+		return nil, errors.New("Token has been revoked")
+	}
+
+	// The roles in the token may be stale; ask the auth service instead.
+	if changedAt, ok := v.changed[principal.UserId]; ok && !principal.issuedAt.After(changedAt) {
+		return nil, errRemoteRequired
+	}
+
+	return principal, nil
+}
+
+func (v *Verifier) validateRemotely(ctx context.Context, token string) (*Principal, error) {
+	res, err := v.client.Validate(ctx, &pb.ValidateRequest{
+		Token: token,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Status != http.StatusOK {
+		return nil, errors.New(res.Error)
+	}
+
+	principal := &Principal{
+		UserId:      res.UserId,
+		Roles:       res.Roles,
+		Permissions: res.Permissions,
+		ClientId:    res.ClientId,
+	}
+
+	claims := &tokenClaims{}
+
+	if _, _, err := new(jwt.Parser).ParseUnverified(token, claims); err == nil {
+		principal.jti = claims.StandardClaims.Id
+		principal.issuedAt = time.Unix(claims.IssuedAt, 0)
+		principal.expiresAt = time.Unix(claims.ExpiresAt, 0)
+	} else if strings.HasPrefix(token, oauthAccessTokenPrefix) {
+		// The auth service names opaque tokens by their hash in notices.
+		principal.jti = cacheKey(token)
+	}
+
+	return principal, nil
+}
+
+func (v *Verifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, err := v.key(kid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if token.Method.Alg() != key.alg {
+		return nil, fmt.Errorf("Unexpected signing method %s", token.Method.Alg())
+	}
+
+	return key.public, nil
+}
+
+// key looks up a verification key, refetching the key set when the kid is
+// unknown since the auth service may have rotated.
+func (v *Verifier) key(kid string) (*verificationKey, error) {
+	v.mu.RLock()
+	key, ok := v.keys[kid]
+	stale := time.Since(v.keysFetched) > jwksRefreshInterval
+	v.mu.RUnlock()
+
+	if ok {
+		return key, nil
+	}
+
+	if stale {
+		if err := v.fetchKeys(); err != nil {
+			return nil, err
+		}
+
+		v.mu.RLock()
+		key, ok = v.keys[kid]
+		v.mu.RUnlock()
+
+		if ok {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Unknown key %q", kid)
+}
+
+func (v *Verifier) fetchKeys() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := v.client.GetJWKS(ctx, &pb.GetJWKSRequest{})
+
+	if err == nil && res.Status != http.StatusOK {
+		err = errors.New(res.Error)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*verificationKey)
+
+	for _, jwk := range res.Keys {
+		public, err := publicKey(jwk)
+
+		if err != nil {
+			log.Println("Skipping key", jwk.Kid+":", err)
+			continue
+		}
+
+		keys[jwk.Kid] = &verificationKey{alg: jwk.Alg, public: public}
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.keysFetched = time.Now()
+	v.mu.Unlock()
+
+	return nil
+}
+
+func publicKey(jwk *pb.JWK) (interface{}, error) {
+	switch jwk.Kty {
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+
+		if err != nil || jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+
+		return ed25519.PublicKey(x), nil
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+}
+
+// Watch follows the revocation feed for as long as the gateway runs.
+func (v *Verifier) Watch() {
+	for {
+		err := v.watch()
+
+		v.mu.Lock()
+		v.watching = false
+		v.mu.Unlock()
+
+		// Notices may be missed until the feed is back, so forget what was
+		// verified locally.
+		v.cache.clear()
+
+		log.Println("Revocation feed interrupted:", err)
+		time.Sleep(watchRetryDelay)
+	}
+}
+
+func (v *Verifier) watch() error {
+	stream, err := v.client.WatchRevocations(context.Background(), &pb.WatchRevocationsRequest{})
+
+	if err != nil {
+		return err
+	}
+
+	for {
+		notice, err := stream.Recv()
+
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case notice.Synced:
+			if err := v.fetchKeys(); err != nil {
+				return err
+			}
+
+			v.mu.Lock()
+			v.watching = true
+			v.mu.Unlock()
+		case notice.Jti != "":
+			v.revoke(notice.Jti, time.Unix(notice.ExpiresAt, 0))
+		case notice.UserId != 0:
+			v.userChanged(notice.UserId, time.Unix(notice.ChangedAt, 0))
+		}
+	}
+}
+
+func (v *Verifier) revoke(jti string, expiresAt time.Time) {
+	if jti == "" {
+		return
+	}
+
+	now := time.Now()
+
+	v.mu.Lock()
+	for revoked, exp := range v.revoked {
+		if exp.Before(now) {
+			delete(v.revoked, revoked)
+		}
+	}
+	v.revoked[jti] = expiresAt
+	v.mu.Unlock()
+
+	v.cache.evict(func(p *Principal) bool { return p.jti == jti })
+}
+
+func (v *Verifier) userChanged(userId int64, changedAt time.Time) {
+	v.mu.Lock()
+	for user, at := range v.changed {
+		if time.Since(at) > userChangeRetention {
+			delete(v.changed, user)
+		}
+	}
+	v.changed[userId] = changedAt
+	v.mu.Unlock()
+
+	v.cache.evict(func(p *Principal) bool { return p.UserId == userId })
+}
+
+func cacheKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,12 +1,21 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 type Config struct {
-	Port          string `mapstructure:"PORT"`
-	AuthSvcUrl    string `mapstructure:"AUTH_SVC_URL"`
-	ProductSvcUrl string `mapstructure:"PRODUCT_SVC_URL"`
-	OrderSvcUrl   string `mapstructure:"ORDER_SVC_URL"`
+	Port          string        `mapstructure:"PORT"`
+	AuthSvcUrl    string        `mapstructure:"AUTH_SVC_URL"`
+	ProductSvcUrl string        `mapstructure:"PRODUCT_SVC_URL"`
+	OrderSvcUrl   string        `mapstructure:"ORDER_SVC_URL"`
+	AuthCacheSize int           `mapstructure:"AUTH_CACHE_SIZE"`
+	AuthCacheTTL  time.Duration `mapstructure:"AUTH_CACHE_TTL"`
+	// TrustedProxies lists the addresses and CIDRs of the proxies whose
+	// X-Forwarded-For header is believed. Empty trusts no one.
+	TrustedProxies []string `mapstructure:"TRUSTED_PROXIES"`
 }
 
 func LoadConfig() (c Config, err error) {
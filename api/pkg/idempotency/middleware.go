@@ -0,0 +1,28 @@
+package idempotency
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	Header      = "Idempotency-Key"
+	MetadataKey = "idempotency-key"
+)
+
+// ForwardKey passes the Idempotency-Key header of mutating requests on to the
+// backend services as gRPC metadata of the request context.
+func ForwardKey(ctx *gin.Context) {
+	key := ctx.Request.Header.Get(Header)
+
+	switch ctx.Request.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		if key != "" {
+			ctx.Request = ctx.Request.WithContext(metadata.AppendToOutgoingContext(ctx.Request.Context(), MetadataKey, key))
+		}
+	}
+
+	ctx.Next()
+}
@@ -1,6 +1,8 @@
 package order
 
 import (
+	"strconv"
+
 	"api/pkg/auth"
 	"api/pkg/config"
 	"api/pkg/order/routes"
@@ -16,9 +18,44 @@ func RegisterRoutes(r *gin.Engine, c *config.Config, authSvc *auth.ServiceClient
 
 	routes := r.Group("/order")
 	routes.Use(a.AuthRequired)
-	routes.POST("/", svc.CreateOrder)
+	routes.POST("/", a.RequirePermission(auth.PermissionOrderWrite), svc.CreateOrder)
+	routes.GET("/", a.RequirePermission(auth.PermissionOrderRead), svc.ListOrders)
+	routes.GET("/:id", a.RequirePermission(auth.PermissionOrderRead), svc.GetOrder)
+	routes.PATCH("/:id", a.RequirePermission(auth.PermissionOrderWrite), svc.UpdateOrder)
+	routes.DELETE("/:id", a.RequirePermission(auth.PermissionOrderWrite), svc.DeleteOrder)
 }
 
 func (svc *ServiceClient) CreateOrder(ctx *gin.Context) {
 	routes.CreateOrder(ctx, svc.Client)
 }
+
+func (svc *ServiceClient) ListOrders(ctx *gin.Context) {
+	routes.ListOrders(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) GetOrder(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	routes.GetOrder(ctx, svc.Client, id)
+}
+
+func (svc *ServiceClient) UpdateOrder(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	routes.UpdateOrder(ctx, svc.Client, id)
+}
+
+func (svc *ServiceClient) DeleteOrder(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	routes.DeleteOrder(ctx, svc.Client, id)
+}
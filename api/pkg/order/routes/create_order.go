@@ -1,18 +1,25 @@
 package routes
 
 import (
-	"context"
 	"net/http"
 
 	"api/pkg/order/pb"
 	"github.com/gin-gonic/gin"
 )
 
-type CreateOrderRequestBody struct {
+type OrderItemBody struct {
 	ProductId int64 `json:"productId"`
+	VariantId int64 `json:"variantId"`
 	Quantity  int64 `json:"quantity"`
 }
 
+type CreateOrderRequestBody struct {
+	ProductId int64           `json:"productId"`
+	VariantId int64           `json:"variantId"`
+	Quantity  int64           `json:"quantity"`
+	Items     []OrderItemBody `json:"items"`
+}
+
 func CreateOrder(ctx *gin.Context, c pb.OrderServiceClient) {
 	b := CreateOrderRequestBody{}
 
@@ -23,10 +30,22 @@ func CreateOrder(ctx *gin.Context, c pb.OrderServiceClient) {
 
 	userId, _ := ctx.Get("userId")
 
-	res, err := c.CreateOrder(context.Background(), &pb.CreateOrderRequest{
+	items := make([]*pb.OrderItem, 0, len(b.Items))
+
+	for _, item := range b.Items {
+		items = append(items, &pb.OrderItem{
+			ProductId: item.ProductId,
+			VariantId: item.VariantId,
+			Quantity:  item.Quantity,
+		})
+	}
+
+	res, err := c.CreateOrder(ctx.Request.Context(), &pb.CreateOrderRequest{
 		ProductId: b.ProductId,
+		VariantId: b.VariantId,
 		Quantity:  b.Quantity,
 		UserId:    userId.(int64),
+		Items:     items,
 	})
 
 	if err != nil {
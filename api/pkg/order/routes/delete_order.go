@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"net/http"
+
+	"api/pkg/order/pb"
+	"github.com/gin-gonic/gin"
+)
+
+func DeleteOrder(ctx *gin.Context, c pb.OrderServiceClient, id int64) {
+	if _, ok := findOwnOrder(ctx, c, id); !ok {
+		return
+	}
+
+	res, err := c.DeleteOrder(ctx.Request.Context(), &pb.DeleteOrderRequest{
+		Id: id,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
@@ -0,0 +1,51 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+
+	"api/pkg/auth"
+	"api/pkg/order/pb"
+	"github.com/gin-gonic/gin"
+)
+
+func GetOrder(ctx *gin.Context, c pb.OrderServiceClient, id int64) {
+	order, ok := findOwnOrder(ctx, c, id)
+
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &pb.GetOrderResponse{
+		Status: http.StatusOK,
+		Order:  order,
+	})
+}
+
+// findOwnOrder loads an order and checks that it belongs to the authenticated
+// user. Orders of other users are reported as not found so their ids cannot be
+// probed. It writes the error response itself and returns false on failure.
+func findOwnOrder(ctx *gin.Context, c pb.OrderServiceClient, id int64) (*pb.Order, bool) {
+	res, err := c.GetOrder(context.Background(), &pb.GetOrderRequest{
+		Id: id,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return nil, false
+	}
+
+	if res.Status != http.StatusOK {
+		ctx.JSON(int(res.Status), &res)
+		return nil, false
+	}
+
+	userId, _ := ctx.Get("userId")
+
+	if res.Order.UserId != userId.(int64) && !auth.HasRole(ctx, auth.RoleAdmin) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return nil, false
+	}
+
+	return res.Order, true
+}
@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"api/pkg/auth"
+	"api/pkg/order/pb"
+	"github.com/gin-gonic/gin"
+)
+
+func ListOrders(ctx *gin.Context, c pb.OrderServiceClient) {
+	userId, _ := ctx.Get("userId")
+
+	req := pb.ListOrdersRequest{
+		UserId:        userId.(int64),
+		Status:        ctx.Query("status"),
+		CreatedAfter:  ctx.Query("created_after"),
+		CreatedBefore: ctx.Query("created_before"),
+		SortBy:        ctx.Query("sort_by"),
+		SortOrder:     ctx.Query("sort_order"),
+		PageToken:     ctx.Query("page_token"),
+	}
+	req.PageSize, _ = strconv.ParseInt(ctx.Query("page_size"), 10, 32)
+	req.IncludeTotal, _ = strconv.ParseBool(ctx.Query("include_total"))
+
+	// Admins see every user's orders unless they ask for a specific user.
+	if auth.HasRole(ctx, auth.RoleAdmin) {
+		req.UserId, _ = strconv.ParseInt(ctx.Query("user_id"), 10, 64)
+	}
+
+	res, err := c.ListOrders(context.Background(), &req)
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"net/http"
+
+	"api/pkg/auth"
+	"api/pkg/order/pb"
+	"github.com/gin-gonic/gin"
+)
+
+type UpdateOrderRequestBody struct {
+	UserId int64 `json:"userId"`
+}
+
+func UpdateOrder(ctx *gin.Context, c pb.OrderServiceClient, id int64) {
+	b := UpdateOrderRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	order, ok := findOwnOrder(ctx, c, id)
+
+	if !ok {
+		return
+	}
+
+	if b.UserId == 0 {
+		b.UserId = order.UserId
+	}
+
+	// Only admins may hand an order over to another user.
+	if b.UserId != order.UserId && !auth.HasRole(ctx, auth.RoleAdmin) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Cannot reassign order"})
+		return
+	}
+
+	res, err := c.UpdateOrder(ctx.Request.Context(), &pb.UpdateOrderRequest{
+		Id:     id,
+		UserId: b.UserId,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
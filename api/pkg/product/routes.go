@@ -16,12 +16,31 @@ func RegisterRoutes(r *gin.Engine, c *config.Config, authSvc *auth.ServiceClient
 	}
 
 	productRoutes := r.Group("/product")
-	productRoutes.Use(a.AuthRequired)
-	productRoutes.POST("/", svc.CreateProduct)
+	productRoutes.Use(a.AuthRequired, a.RequirePermission(auth.PermissionProductRead))
+	productRoutes.GET("/search", svc.SearchProducts)
+	productRoutes.GET("/sku/:sku", svc.FindOneBySku)
 	productRoutes.GET("/:id", svc.FindOne)
 	productRoutes.GET("/", svc.ListProducts)
-	productRoutes.PUT("/:id", svc.UpdateProduct)
-	productRoutes.DELETE("/:id", svc.DeleteProduct)
+
+	adminRoutes := productRoutes.Group("")
+	adminRoutes.Use(a.RequirePermission(auth.PermissionProductWrite))
+	adminRoutes.POST("/", svc.CreateProduct)
+	adminRoutes.PUT("/:id", svc.UpdateProduct)
+	adminRoutes.DELETE("/:id", svc.DeleteProduct)
+	adminRoutes.POST("/:id/variants", svc.CreateVariant)
+	adminRoutes.PUT("/:id/variants/:variant_id", svc.UpdateVariant)
+	adminRoutes.DELETE("/:id/variants/:variant_id", svc.DeleteVariant)
+
+	categoryRoutes := r.Group("/category")
+	categoryRoutes.Use(a.AuthRequired, a.RequirePermission(auth.PermissionProductRead))
+	categoryRoutes.GET("/", svc.ListCategories)
+	categoryRoutes.GET("/:id", svc.ReadCategory)
+
+	categoryAdminRoutes := categoryRoutes.Group("")
+	categoryAdminRoutes.Use(a.RequirePermission(auth.PermissionProductWrite))
+	categoryAdminRoutes.POST("/", svc.CreateCategory)
+	categoryAdminRoutes.PUT("/:id", svc.UpdateCategory)
+	categoryAdminRoutes.DELETE("/:id", svc.DeleteCategory)
 }
 
 func (svc *ServiceClient) FindOne(ctx *gin.Context) {
@@ -33,6 +52,10 @@ func (svc *ServiceClient) FindOne(ctx *gin.Context) {
 	routes.FineOne(ctx, svc.Client, id)
 }
 
+func (svc *ServiceClient) FindOneBySku(ctx *gin.Context) {
+	routes.FindOneBySku(ctx, svc.Client, ctx.Param("sku"))
+}
+
 func (svc *ServiceClient) CreateProduct(ctx *gin.Context) {
 	routes.CreateProduct(ctx, svc.Client)
 }
@@ -41,6 +64,10 @@ func (svc *ServiceClient) ListProducts(ctx *gin.Context) {
 	routes.ListProducts(ctx, svc.Client)
 }
 
+func (svc *ServiceClient) SearchProducts(ctx *gin.Context) {
+	routes.SearchProducts(ctx, svc.Client)
+}
+
 func (svc *ServiceClient) UpdateProduct(ctx *gin.Context) {
 	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
 	if err != nil {
@@ -58,3 +85,75 @@ func (svc *ServiceClient) DeleteProduct(ctx *gin.Context) {
 	}
 	routes.DeleteProduct(ctx, svc.Client, id)
 }
+
+func (svc *ServiceClient) CreateCategory(ctx *gin.Context) {
+	routes.CreateCategory(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) ListCategories(ctx *gin.Context) {
+	routes.ListCategories(ctx, svc.Client)
+}
+
+func (svc *ServiceClient) ReadCategory(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid category ID"})
+		return
+	}
+	routes.ReadCategory(ctx, svc.Client, id)
+}
+
+func (svc *ServiceClient) UpdateCategory(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid category ID"})
+		return
+	}
+	routes.UpdateCategory(ctx, svc.Client, id)
+}
+
+func (svc *ServiceClient) DeleteCategory(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid category ID"})
+		return
+	}
+	routes.DeleteCategory(ctx, svc.Client, id)
+}
+
+func (svc *ServiceClient) CreateVariant(ctx *gin.Context) {
+	productId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid product ID"})
+		return
+	}
+	routes.CreateVariant(ctx, svc.Client, productId)
+}
+
+func (svc *ServiceClient) UpdateVariant(ctx *gin.Context) {
+	productId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid product ID"})
+		return
+	}
+	id, err := strconv.ParseInt(ctx.Param("variant_id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid variant ID"})
+		return
+	}
+	routes.UpdateVariant(ctx, svc.Client, productId, id)
+}
+
+func (svc *ServiceClient) DeleteVariant(ctx *gin.Context) {
+	productId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid product ID"})
+		return
+	}
+	id, err := strconv.ParseInt(ctx.Param("variant_id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid variant ID"})
+		return
+	}
+	routes.DeleteVariant(ctx, svc.Client, productId, id)
+}
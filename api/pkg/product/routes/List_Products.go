@@ -18,11 +18,45 @@ func ListProducts(ctx *gin.Context, c pb.ProductServiceClient) {
 	req.SortOrder = ctx.Query("sort_order")
 	req.Page, _ = strconv.ParseInt(ctx.Query("page"), 10, 32)
 	req.PageSize, _ = strconv.ParseInt(ctx.Query("page_size"), 10, 32)
+	req.CategoryId, _ = strconv.ParseInt(ctx.Query("category_id"), 10, 64)
+	req.Tag = ctx.Query("tag")
+	req.IncludeSubcategories, _ = strconv.ParseBool(ctx.Query("include_subcategories"))
+	req.PageToken = ctx.Query("page_token")
+	req.IncludeTotal, _ = strconv.ParseBool(ctx.Query("include_total"))
+
+	bounds := map[string]**int64{
+		"min_price": &req.MinPrice,
+		"max_price": &req.MaxPrice,
+		"min_stock": &req.MinStock,
+		"max_stock": &req.MaxStock,
+	}
+
+	for param, field := range bounds {
+		value, ok := ctx.GetQuery(param)
+
+		if !ok {
+			continue
+		}
+
+		bound, err := strconv.ParseInt(value, 10, 64)
+
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": param + " must be an integer"})
+			return
+		}
+
+		*field = &bound
+	}
 
 	res, err := c.ListProducts(context.Background(), &req)
 
-	if err != nil || res.Status != http.StatusOK {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": res.Error})
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	if res.Status != http.StatusOK {
+		ctx.JSON(int(res.Status), gin.H{"error": res.Error})
 		return
 	}
 
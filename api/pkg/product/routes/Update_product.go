@@ -3,7 +3,6 @@ package routes
 
 import (
 	"api/pkg/product/pb"
-	"context"
 	"github.com/gin-gonic/gin"
 	"net/http"
 )
@@ -18,7 +17,7 @@ func UpdateProduct(ctx *gin.Context, client pb.ProductServiceClient, id int64) {
 
 	req.Id = id
 
-	res, err := client.UpdateProduct(context.Background(), &req)
+	res, err := client.UpdateProduct(ctx.Request.Context(), &req)
 
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -0,0 +1,89 @@
+package routes
+
+import (
+	"net/http"
+
+	"api/pkg/product/pb"
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryRequestBody struct {
+	Name     string `json:"name"`
+	ParentId int64  `json:"parentId"`
+}
+
+func CreateCategory(ctx *gin.Context, c pb.ProductServiceClient) {
+	b := CategoryRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := c.CreateCategory(ctx.Request.Context(), &pb.CreateCategoryRequest{
+		Name:     b.Name,
+		ParentId: b.ParentId,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+func ReadCategory(ctx *gin.Context, c pb.ProductServiceClient, id int64) {
+	res, err := c.ReadCategory(ctx.Request.Context(), &pb.ReadCategoryRequest{Id: id})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+func ListCategories(ctx *gin.Context, c pb.ProductServiceClient) {
+	res, err := c.ListCategories(ctx.Request.Context(), &pb.ListCategoriesRequest{})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+func UpdateCategory(ctx *gin.Context, c pb.ProductServiceClient, id int64) {
+	b := CategoryRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := c.UpdateCategory(ctx.Request.Context(), &pb.UpdateCategoryRequest{
+		Id:       id,
+		Name:     b.Name,
+		ParentId: b.ParentId,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+func DeleteCategory(ctx *gin.Context, c pb.ProductServiceClient, id int64) {
+	res, err := c.DeleteCategory(ctx.Request.Context(), &pb.DeleteCategoryRequest{Id: id})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
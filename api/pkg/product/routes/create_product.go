@@ -1,7 +1,6 @@
 package routes
 
 import (
-	"context"
 	"net/http"
 
 	"api/pkg/product/pb"
@@ -9,9 +8,13 @@ import (
 )
 
 type CreateProductRequestBody struct {
-	Name  string `json:"name"`
-	Stock int64  `json:"stock"`
-	Price int64  `json:"price"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Sku         string   `json:"sku"`
+	Stock       int64    `json:"stock"`
+	Price       int64    `json:"price"`
+	CategoryIds []int64  `json:"categoryIds"`
+	Tags        []string `json:"tags"`
 }
 
 func CreateProduct(ctx *gin.Context, c pb.ProductServiceClient) {
@@ -22,10 +25,14 @@ func CreateProduct(ctx *gin.Context, c pb.ProductServiceClient) {
 		return
 	}
 
-	res, err := c.CreateProduct(context.Background(), &pb.CreateProductRequest{
-		Name:  b.Name,
-		Stock: b.Stock,
-		Price: b.Price,
+	res, err := c.CreateProduct(ctx.Request.Context(), &pb.CreateProductRequest{
+		Name:        b.Name,
+		Description: b.Description,
+		Sku:         b.Sku,
+		Stock:       b.Stock,
+		Price:       b.Price,
+		CategoryIds: b.CategoryIds,
+		Tags:        b.Tags,
 	})
 
 	if err != nil {
@@ -33,5 +40,5 @@ func CreateProduct(ctx *gin.Context, c pb.ProductServiceClient) {
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, &res)
+	ctx.JSON(int(res.Status), &res)
 }
@@ -3,7 +3,6 @@ package routes
 
 import (
 	"api/pkg/product/pb"
-	"context"
 	"github.com/gin-gonic/gin"
 	"net/http"
 )
@@ -13,7 +12,7 @@ func DeleteProduct(ctx *gin.Context, client pb.ProductServiceClient, id int64) {
 		Id: id,
 	}
 
-	res, err := client.DeleteProduct(context.Background(), req)
+	res, err := client.DeleteProduct(ctx.Request.Context(), req)
 
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -21,3 +21,14 @@ func FineOne(ctx *gin.Context, client pb.ProductServiceClient, id int64) {
 
 	ctx.JSON(int(res.Status), res)
 }
+
+func FindOneBySku(ctx *gin.Context, client pb.ProductServiceClient, sku string) {
+	res, err := client.FindOne(ctx.Request.Context(), &pb.FindOneRequest{Sku: sku})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), res)
+}
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"api/pkg/product/pb"
+	"github.com/gin-gonic/gin"
+)
+
+func SearchProducts(ctx *gin.Context, c pb.ProductServiceClient) {
+	req := pb.SearchProductsRequest{
+		Query: ctx.Query("q"),
+	}
+
+	req.Page, _ = strconv.ParseInt(ctx.Query("page"), 10, 32)
+	req.PageSize, _ = strconv.ParseInt(ctx.Query("page_size"), 10, 32)
+
+	res, err := c.SearchProducts(ctx.Request.Context(), &req)
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
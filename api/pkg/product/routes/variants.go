@@ -0,0 +1,82 @@
+package routes
+
+import (
+	"net/http"
+
+	"api/pkg/product/pb"
+	"github.com/gin-gonic/gin"
+)
+
+type VariantRequestBody struct {
+	Sku   string `json:"sku"`
+	Size  string `json:"size"`
+	Color string `json:"color"`
+	// Leave out to sell at the price of the product.
+	Price *int64 `json:"price"`
+	Stock int64  `json:"stock"`
+}
+
+func CreateVariant(ctx *gin.Context, c pb.ProductServiceClient, productId int64) {
+	b := VariantRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := c.CreateVariant(ctx.Request.Context(), &pb.CreateVariantRequest{
+		ProductId: productId,
+		Sku:       b.Sku,
+		Size:      b.Size,
+		Color:     b.Color,
+		Price:     b.Price,
+		Stock:     b.Stock,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+func UpdateVariant(ctx *gin.Context, c pb.ProductServiceClient, productId int64, id int64) {
+	b := VariantRequestBody{}
+
+	if err := ctx.BindJSON(&b); err != nil {
+		ctx.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := c.UpdateVariant(ctx.Request.Context(), &pb.UpdateVariantRequest{
+		Id:        id,
+		ProductId: productId,
+		Sku:       b.Sku,
+		Size:      b.Size,
+		Color:     b.Color,
+		Price:     b.Price,
+		Stock:     b.Stock,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
+
+func DeleteVariant(ctx *gin.Context, c pb.ProductServiceClient, productId int64, id int64) {
+	res, err := c.DeleteVariant(ctx.Request.Context(), &pb.DeleteVariantRequest{
+		Id:        id,
+		ProductId: productId,
+	})
+
+	if err != nil {
+		ctx.AbortWithError(http.StatusBadGateway, err)
+		return
+	}
+
+	ctx.JSON(int(res.Status), &res)
+}
@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"time"
 
 	"authh/pkg/config"
 	"authh/pkg/db"
+	"authh/pkg/mail"
 	"authh/pkg/pb"
 	"authh/pkg/services"
 	"authh/pkg/utils"
@@ -22,10 +24,20 @@ func main() {
 
 	h := db.Init(c.DBUrl)
 
+	if err := h.GrantAdmin(c.AdminEmail); err != nil {
+		log.Fatalln("Failed to grant the admin role:", err)
+	}
+
+	keys, err := utils.LoadKeySet(c.JWTKeysDir, c.JWTActiveKid)
+
+	if err != nil {
+		log.Fatalln("Failed to load signing keys:", err)
+	}
+
 	jwt := utils.JwtWrapper{
-		SecretKey:       c.JWTSecretKey,
-		Issuer:          "go-grpc-auth-svc",
-		ExpirationHours: 24 * 365,
+		Keys:       keys,
+		Issuer:     "go-grpc-auth-svc",
+		Expiration: c.AccessTokenTTL,
 	}
 
 	lis, err := net.Listen("tcp", c.Port)
@@ -36,12 +48,61 @@ func main() {
 
 	fmt.Println("Auth Svc on", c.Port)
 
+	var mailer mail.Mailer = &mail.LogMailer{Dir: c.MailDir}
+
+	if c.MailDriver == "smtp" {
+		mailer = &mail.SMTPMailer{
+			Addr:     c.SMTPAddr,
+			From:     c.MailFrom,
+			Username: c.SMTPUsername,
+			Password: c.SMTPPassword,
+		}
+	}
+
 	s := services.Server{
-		H:   h,
-		Jwt: jwt,
+		H:               h,
+		Jwt:             jwt,
+		RefreshTokenTTL: c.RefreshTokenTTL,
+		AdminEmail:      c.AdminEmail,
+		Mailer:          mailer,
+		AppURL:          c.AppURL,
+		ResetTokenTTL:   c.ResetTokenTTL,
+		VerifyTokenTTL:  c.VerifyTokenTTL,
+		Limits: services.LoginLimits{
+			MaxFailures:   c.LoginMaxFails,
+			MaxIPFailures: c.LoginMaxIPFails,
+			Backoff:       c.LoginBackoff,
+			Lockout:       c.LoginLockout,
+		},
+		ResetLimits: services.ResetLimits{
+			MaxRequests:   c.ResetMaxReqs,
+			MaxIPRequests: c.ResetMaxIPReqs,
+			Window:        c.ResetWindow,
+		},
+		TOTPIssuer: c.TOTPIssuer,
+		Hasher: &utils.PasswordHasher{
+			Algorithm:     c.PasswordHash,
+			BcryptCost:    c.BcryptCost,
+			Argon2Memory:  c.Argon2Memory,
+			Argon2Time:    c.Argon2Time,
+			Argon2Threads: c.Argon2Threads,
+		},
+		Policy: utils.PasswordPolicy{
+			MinLength:  c.PasswordMinLen,
+			MinClasses: c.PasswordClasses,
+		},
+		OAuth: services.OAuthSettings{
+			AccessTokenTTL:  c.OAuthAccessTTL,
+			RefreshTokenTTL: c.OAuthRefreshTTL,
+			CodeTTL:         c.OAuthCodeTTL,
+		},
+		EventRetention: c.EventRetention,
 	}
 
-	grpcServer := grpc.NewServer()
+	go s.PurgeExpiredTokens(time.Hour)
+	go s.RelayRevocations(c.RevocationPoll)
+
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(s.AuditInterceptor))
 
 	pb.RegisterAuthServiceServer(grpcServer, &s)
 
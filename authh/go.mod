@@ -2,12 +2,16 @@ module authh
 
 go 1.22.0
 
-require google.golang.org/grpc v1.45.0
+require (
+	github.com/golang-jwt/jwt v3.2.2+incompatible
+	github.com/spf13/viper v1.10.1
+	golang.org/x/crypto v0.0.0-20220321153916-2c7772ba3064
+	google.golang.org/grpc v1.45.0
+)
 
 require (
 	aidanwoods.dev/go-paseto v1.0.1 // indirect
 	github.com/fsnotify/fsnotify v1.5.1 // indirect
-	github.com/golang-jwt/jwt v3.2.2+incompatible // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
 	github.com/hellokvn/go-grpc-proto v0.0.0-20220323215141-ce7817ac1f88 // indirect
 	github.com/jackc/chunkreader/v2 v2.0.1 // indirect
@@ -28,9 +32,7 @@ require (
 	github.com/spf13/cast v1.4.1 // indirect
 	github.com/spf13/jwalterweatherman v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	github.com/spf13/viper v1.10.1 // indirect
 	github.com/subosito/gotenv v1.2.0 // indirect
-	golang.org/x/crypto v0.0.0-20220321153916-2c7772ba3064 // indirect
 	gopkg.in/ini.v1 v1.66.2 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
@@ -41,7 +43,7 @@ require (
 	golang.org/x/sys v0.0.0-20220319134239-a9b59b0215f8 // indirect
 	golang.org/x/text v0.3.7 // indirect
 	google.golang.org/genproto v0.0.0-20211208223120-3a66f561d7aa // indirect
-	google.golang.org/protobuf v1.27.1 // indirect
+	google.golang.org/protobuf v1.27.1
 	gorm.io/driver/postgres v1.3.1
 	gorm.io/gorm v1.23.3
 )
@@ -1,11 +1,48 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 type Config struct {
-	Port         string `mapstructure:"PORT"`
-	DBUrl        string `mapstructure:"DB_URL"`
-	JWTSecretKey string `mapstructure:"JWT_SECRET_KEY"`
+	Port            string        `mapstructure:"PORT"`
+	DBUrl           string        `mapstructure:"DB_URL"`
+	JWTKeysDir      string        `mapstructure:"JWT_KEYS_DIR"`
+	JWTActiveKid    string        `mapstructure:"JWT_ACTIVE_KID"`
+	AccessTokenTTL  time.Duration `mapstructure:"ACCESS_TOKEN_TTL"`
+	RefreshTokenTTL time.Duration `mapstructure:"REFRESH_TOKEN_TTL"`
+	AdminEmail      string        `mapstructure:"ADMIN_EMAIL"`
+	AppURL          string        `mapstructure:"APP_URL"`
+	ResetTokenTTL   time.Duration `mapstructure:"RESET_TOKEN_TTL"`
+	VerifyTokenTTL  time.Duration `mapstructure:"VERIFY_TOKEN_TTL"`
+	MailDriver      string        `mapstructure:"MAIL_DRIVER"`
+	MailFrom        string        `mapstructure:"MAIL_FROM"`
+	MailDir         string        `mapstructure:"MAIL_DIR"`
+	SMTPAddr        string        `mapstructure:"SMTP_ADDR"`
+	SMTPUsername    string        `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword    string        `mapstructure:"SMTP_PASSWORD"`
+	LoginMaxFails   int           `mapstructure:"LOGIN_MAX_FAILURES"`
+	LoginMaxIPFails int           `mapstructure:"LOGIN_MAX_IP_FAILURES"`
+	LoginBackoff    time.Duration `mapstructure:"LOGIN_BACKOFF"`
+	LoginLockout    time.Duration `mapstructure:"LOGIN_LOCKOUT"`
+	ResetMaxReqs    int           `mapstructure:"RESET_MAX_REQUESTS"`
+	ResetMaxIPReqs  int           `mapstructure:"RESET_MAX_IP_REQUESTS"`
+	ResetWindow     time.Duration `mapstructure:"RESET_WINDOW"`
+	TOTPIssuer      string        `mapstructure:"TOTP_ISSUER"`
+	PasswordHash    string        `mapstructure:"PASSWORD_HASH"`
+	BcryptCost      int           `mapstructure:"BCRYPT_COST"`
+	Argon2Memory    uint32        `mapstructure:"ARGON2_MEMORY"`
+	Argon2Time      uint32        `mapstructure:"ARGON2_TIME"`
+	Argon2Threads   uint8         `mapstructure:"ARGON2_THREADS"`
+	PasswordMinLen  int           `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordClasses int           `mapstructure:"PASSWORD_MIN_CLASSES"`
+	OAuthAccessTTL  time.Duration `mapstructure:"OAUTH_ACCESS_TOKEN_TTL"`
+	OAuthRefreshTTL time.Duration `mapstructure:"OAUTH_REFRESH_TOKEN_TTL"`
+	OAuthCodeTTL    time.Duration `mapstructure:"OAUTH_CODE_TTL"`
+	EventRetention  time.Duration `mapstructure:"AUTH_EVENT_RETENTION"`
+	RevocationPoll  time.Duration `mapstructure:"REVOCATION_POLL_INTERVAL"`
 }
 
 func LoadConfig() (config Config, err error) {
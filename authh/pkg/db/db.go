@@ -19,10 +19,52 @@ func Init(url string) Handler {
 		log.Fatalln(err)
 	}
 
+	db.AutoMigrate(&models.Role{})
 	db.AutoMigrate(&models.User{})
+	db.AutoMigrate(&models.RefreshToken{})
+	db.AutoMigrate(&models.RevokedToken{})
+	db.AutoMigrate(&models.UserChange{})
+	db.AutoMigrate(&models.ActionToken{})
+	db.AutoMigrate(&models.LoginThrottle{})
+	db.AutoMigrate(&models.AuthEvent{})
+	db.AutoMigrate(&models.RecoveryCode{})
+	db.AutoMigrate(&models.APIKey{})
+	db.AutoMigrate(&models.OAuthClient{})
+	db.AutoMigrate(&models.OAuthCode{})
+	db.AutoMigrate(&models.OAuthToken{})
+
+	// Accounts created before users had timestamps.
+	db.Exec("UPDATE users SET created_at = now() WHERE created_at IS NULL")
+	db.Exec("UPDATE users SET updated_at = created_at WHERE updated_at IS NULL")
+
+	for _, name := range models.RoleNames() {
+		if result := db.Where(&models.Role{Name: name}).FirstOrCreate(&models.Role{Name: name}); result.Error != nil {
+			log.Println("Failed to seed role:", result.Error)
+		}
+	}
+
+	// Accounts created before roles existed keep the access they had.
+	if result := db.Exec(`INSERT INTO user_roles (user_id, role_id)
+		SELECT u.id, r.id FROM users u JOIN roles r ON r.name = ?
+		WHERE NOT EXISTS (SELECT 1 FROM user_roles ur WHERE ur.user_id = u.id)`, models.RoleUser); result.Error != nil {
+		log.Fatalln("Failed to backfill roles:", result.Error)
+	}
 
 	return Handler{db}
 }
+
+// GrantAdmin makes the account with the e-mail an admin if it exists. Register
+// only grants ADMIN_EMAIL the role to accounts created afterwards, so this
+// gives an existing deployment its first admin. Running it again is a no-op.
+func (h *Handler) GrantAdmin(email string) error {
+	if email == "" {
+		return nil
+	}
+
+	return h.DB.Exec(`INSERT INTO user_roles (user_id, role_id)
+		SELECT u.id, r.id FROM users u JOIN roles r ON r.name = ?
+		WHERE u.email = ? AND NOT EXISTS (SELECT 1 FROM user_roles ur WHERE ur.user_id = u.id AND ur.role_id = r.id)`, models.RoleAdmin, email).Error
+}
 func (h *Handler) Close() {
 	sqlDB, err := h.DB.DB()
 	if err != nil {
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+const (
+	PurposePasswordReset     = "password_reset"
+	PurposeEmailVerification = "email_verification"
+	PurposeLoginChallenge    = "login_challenge"
+)
+
+// ActionToken is a single-use token that confirms an action, like a link
+// mailed to the user or the challenge between the two steps of a login.
+type ActionToken struct {
+	Id        int64      `json:"id" gorm:"primaryKey"`
+	UserId    int64      `json:"user_id" gorm:"index"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKey lets a program act as its owner with a subset of the owner's
+// permissions. Scopes are stored space separated.
+type APIKey struct {
+	Id         int64      `json:"id" gorm:"primaryKey"`
+	UserId     int64      `json:"user_id" gorm:"index"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix" gorm:"uniqueIndex"`
+	KeyHash    string     `json:"-" gorm:"uniqueIndex"`
+	Scopes     string     `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (k APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
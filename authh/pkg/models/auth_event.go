@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+const (
+	AuthEventSuccess = "success"
+	AuthEventFailure = "failure"
+)
+
+// AuthEventLockout is written when an account or IP gets locked out; the
+// other event types are named after the RPC that caused them.
+const AuthEventLockout = "lockout"
+
+// AuthEvent is an entry of the audit log. Rows are only ever inserted, and
+// deleted once they are older than the configured retention.
+type AuthEvent struct {
+	Id        int64     `json:"id" gorm:"primaryKey"`
+	Type      string    `json:"type" gorm:"index"`
+	UserId    int64     `json:"user_id" gorm:"index"`
+	ActorId   int64     `json:"actor_id" gorm:"index"`
+	Email     string    `json:"email"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Result    string    `json:"result"`
+	Status    int64     `json:"status"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
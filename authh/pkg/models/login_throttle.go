@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// LoginThrottle counts recent failed logins for an account ("email:...") or
+// a client IP ("ip:..."). For password reset requests ("reset-email:...",
+// "reset-ip:...") it counts the requests of a window ending at LockedUntil.
+type LoginThrottle struct {
+	Key           string     `json:"key" gorm:"primaryKey"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
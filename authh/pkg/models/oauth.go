@@ -0,0 +1,82 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	OAuthTokenAccess  = "access"
+	OAuthTokenRefresh = "refresh"
+)
+
+// OAuthClient is a third-party application registered by a user. Tokens
+// issued to it act as that user (client_credentials) or as the user who
+// approved it (authorization_code), limited to Scopes.
+type OAuthClient struct {
+	Id           int64     `json:"id" gorm:"primaryKey"`
+	ClientId     string    `json:"client_id" gorm:"uniqueIndex"`
+	SecretHash   string    `json:"-"`
+	Name         string    `json:"name"`
+	OwnerId      int64     `json:"owner_id" gorm:"index"`
+	RedirectURIs string    `json:"redirect_uris"`
+	Scopes       string    `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+func (c OAuthClient) RedirectURIList() []string {
+	return strings.Fields(c.RedirectURIs)
+}
+
+func (c OAuthClient) ScopeList() []string {
+	return strings.Fields(c.Scopes)
+}
+
+// OAuthCode is an authorization code waiting to be exchanged for tokens.
+// GrantId is set on redemption so that a replayed code can revoke what the
+// first redemption issued.
+type OAuthCode struct {
+	Id            int64      `json:"id" gorm:"primaryKey"`
+	CodeHash      string     `json:"-" gorm:"uniqueIndex"`
+	ClientId      string     `json:"client_id" gorm:"index"`
+	UserId        int64      `json:"user_id" gorm:"index"`
+	RedirectURI   string     `json:"redirect_uri"`
+	Scopes        string     `json:"scopes"`
+	CodeChallenge string     `json:"-"`
+	GrantId       string     `json:"grant_id"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	UsedAt        *time.Time `json:"used_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (OAuthCode) TableName() string {
+	return "oauth_codes"
+}
+
+// OAuthToken is an opaque access or refresh token. Tokens that descend from
+// the same authorization share a GrantId.
+type OAuthToken struct {
+	Id        int64      `json:"id" gorm:"primaryKey"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	Kind      string     `json:"kind"`
+	GrantId   string     `json:"grant_id" gorm:"index"`
+	ClientId  string     `json:"client_id" gorm:"index"`
+	UserId    int64      `json:"user_id" gorm:"index"`
+	Scopes    string     `json:"scopes"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (OAuthToken) TableName() string {
+	return "oauth_tokens"
+}
+
+func (t OAuthToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RecoveryCode stands in for a TOTP code once, for users who lost their
+// authenticator.
+type RecoveryCode struct {
+	Id        int64      `json:"id" gorm:"primaryKey"`
+	UserId    int64      `json:"user_id" gorm:"index"`
+	CodeHash  string     `json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+type RevokedToken struct {
+	Jti       string    `json:"jti" gorm:"primaryKey"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	RevokedAt time.Time `json:"revoked_at" gorm:"index"`
+}
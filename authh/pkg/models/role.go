@@ -0,0 +1,74 @@
+package models
+
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+const (
+	PermissionProductRead  = "product:read"
+	PermissionProductWrite = "product:write"
+	PermissionOrderRead    = "order:read"
+	PermissionOrderWrite   = "order:write"
+	PermissionUserManage   = "user:manage"
+)
+
+type Role struct {
+	Id   int64  `json:"id" gorm:"primaryKey"`
+	Name string `json:"name" gorm:"uniqueIndex;not null"`
+}
+
+var rolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermissionProductRead,
+		PermissionProductWrite,
+		PermissionOrderRead,
+		PermissionOrderWrite,
+		PermissionUserManage,
+	},
+	RoleUser: {
+		PermissionProductRead,
+		PermissionOrderRead,
+		PermissionOrderWrite,
+	},
+}
+
+func IsRole(name string) bool {
+	_, ok := rolePermissions[name]
+	return ok
+}
+
+// IsPermission reports whether any role grants the permission.
+func IsPermission(name string) bool {
+	for _, permissions := range rolePermissions {
+		for _, permission := range permissions {
+			if permission == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// RoleNames lists every role the service knows about.
+func RoleNames() []string {
+	return []string{RoleAdmin, RoleUser}
+}
+
+// Permissions returns the union of the permissions granted by the roles.
+func Permissions(roles []string) []string {
+	seen := make(map[string]bool)
+	var permissions []string
+
+	for _, role := range roles {
+		for _, permission := range rolePermissions[role] {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+
+	return permissions
+}
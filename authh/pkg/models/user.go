@@ -1,7 +1,29 @@
 package models
 
+import "time"
+
 type User struct {
-	Id       int64  `json:"id" gorm:"primaryKey"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Id            int64      `json:"id" gorm:"primaryKey"`
+	Email         string     `json:"email"`
+	Password      string     `json:"password"`
+	Name          string     `json:"name"`
+	EmailVerified bool       `json:"email_verified"`
+	Disabled      bool       `json:"disabled"`
+	TOTPSecret    string     `json:"-"`
+	TOTPEnabled   bool       `json:"totp_enabled"`
+	TOTPLastStep  int64      `json:"-"`
+	Roles         []Role     `json:"roles" gorm:"many2many:user_roles;"`
+	LastLoginAt   *time.Time `json:"last_login_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (u *User) RoleNames() []string {
+	names := make([]string, 0, len(u.Roles))
+
+	for _, role := range u.Roles {
+		names = append(names, role.Name)
+	}
+
+	return names
 }
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// UserChange remembers the last time a user was disabled, deleted or given
+// other roles, for as long as access tokens issued before it stay valid.
+type UserChange struct {
+	UserId    int64     `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	ChangedAt time.Time `json:"changed_at"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"authh/pkg/mail"
+	"authh/pkg/models"
+	"authh/pkg/pb"
+	"authh/pkg/utils"
+	"gorm.io/gorm"
+)
+
+var errInvalidActionToken = errors.New("Invalid or expired token")
+
+// RequestPasswordReset mails a reset link. It answers the same whether or not
+// the address belongs to an account so that it can't be used to probe for
+// users. Requests are limited per address and per client IP, whether or not
+// the address is known.
+func (s *Server) RequestPasswordReset(ctx context.Context, req *pb.RequestPasswordResetRequest) (*pb.RequestPasswordResetResponse, error) {
+	if req.Email == "" {
+		return &pb.RequestPasswordResetResponse{
+			Status: http.StatusBadRequest,
+			Error:  "E-Mail is required",
+		}, nil
+	}
+
+	if delay := s.resetDelay(req.Email, clientIP(ctx)); delay > 0 {
+		return &pb.RequestPasswordResetResponse{
+			Status: http.StatusTooManyRequests,
+			Error:  tooManyResets(delay),
+		}, nil
+	}
+
+	var user models.User
+
+	if result := s.H.DB.Where("email = ?", req.Email).First(&user); result.Error == nil {
+		auditUser(ctx, user.Id)
+
+		token, err := s.issueActionToken(user.Id, models.PurposePasswordReset, s.ResetTokenTTL)
+
+		if err == nil {
+			err = s.mailer().Send(mail.Message{
+				To:      user.Email,
+				Subject: "Reset your password",
+				Body: fmt.Sprintf("Someone asked to reset the password of your account. "+
+					"Follow the link below to choose a new one:\n\n%s\n\n"+
+					"If it wasn't you, you can ignore this message.", s.link("/reset-password", token)),
+			})
+		}
+
+		if err != nil {
+			log.Println("Failed to send password reset:", err)
+		}
+	}
+
+	return &pb.RequestPasswordResetResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+// ResetPassword sets a new password and ends every session of the user.
+func (s *Server) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest) (*pb.ResetPasswordResponse, error) {
+	if err := s.Policy.Check(req.Password, ""); err != nil {
+		return &pb.ResetPasswordResponse{
+			Status: http.StatusBadRequest,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	hash, err := s.hasher().Hash(req.Password)
+
+	if err != nil {
+		return &pb.ResetPasswordResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	err = s.H.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeActionToken(tx, models.PurposePasswordReset, req.Token)
+
+		if err != nil {
+			return err
+		}
+
+		auditUser(ctx, token.UserId)
+
+		// Following the link proves the address belongs to the user too.
+		result := tx.Model(&models.User{Id: token.UserId}).Updates(map[string]interface{}{
+			"password":       hash,
+			"email_verified": true,
+		})
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", token.UserId).
+			Update("revoked_at", time.Now()).Error
+	})
+
+	if errors.Is(err, errInvalidActionToken) {
+		return &pb.ResetPasswordResponse{
+			Status: http.StatusBadRequest,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	if err != nil {
+		return &pb.ResetPasswordResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	return &pb.ResetPasswordResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+func (s *Server) SendVerification(ctx context.Context, req *pb.SendVerificationRequest) (*pb.SendVerificationResponse, error) {
+	var user models.User
+
+	if result := s.H.DB.First(&user, req.UserId); result.Error != nil {
+		return &pb.SendVerificationResponse{
+			Status: http.StatusNotFound,
+			Error:  "User not found",
+		}, nil
+	}
+
+	if user.EmailVerified {
+		return &pb.SendVerificationResponse{
+			Status: http.StatusConflict,
+			Error:  "E-Mail already verified",
+		}, nil
+	}
+
+	if err := s.sendVerification(user); err != nil {
+		return &pb.SendVerificationResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	return &pb.SendVerificationResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+func (s *Server) VerifyEmail(ctx context.Context, req *pb.VerifyEmailRequest) (*pb.VerifyEmailResponse, error) {
+	err := s.H.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeActionToken(tx, models.PurposeEmailVerification, req.Token)
+
+		if err != nil {
+			return err
+		}
+
+		auditUser(ctx, token.UserId)
+
+		return tx.Model(&models.User{Id: token.UserId}).Update("email_verified", true).Error
+	})
+
+	if errors.Is(err, errInvalidActionToken) {
+		return &pb.VerifyEmailResponse{
+			Status: http.StatusBadRequest,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	if err != nil {
+		return &pb.VerifyEmailResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	return &pb.VerifyEmailResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+func (s *Server) sendVerification(user models.User) error {
+	token, err := s.issueActionToken(user.Id, models.PurposeEmailVerification, s.VerifyTokenTTL)
+
+	if err != nil {
+		return err
+	}
+
+	return s.mailer().Send(mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your e-mail address",
+		Body: fmt.Sprintf("Follow the link below to confirm your e-mail address:\n\n%s",
+			s.link("/verify-email", token)),
+	})
+}
+
+// issueActionToken creates a new token for the purpose and invalidates any
+// earlier one, so only the most recent mail works.
+func (s *Server) issueActionToken(userId int64, purpose string, ttl time.Duration) (string, error) {
+	var token string
+
+	err := s.H.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ActionToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userId, purpose).
+			Update("used_at", time.Now())
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var err error
+		token, err = createActionToken(tx, userId, purpose, ttl)
+
+		return err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func createActionToken(tx *gorm.DB, userId int64, purpose string, ttl time.Duration) (string, error) {
+	token, err := utils.RandomToken(32)
+
+	if err != nil {
+		return "", err
+	}
+
+	stored := models.ActionToken{
+		UserId:    userId,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	if result := tx.Create(&stored); result.Error != nil {
+		return "", result.Error
+	}
+
+	return token, nil
+}
+
+// findActionToken looks up a token that is still usable without using it.
+func findActionToken(tx *gorm.DB, purpose string, token string) (*models.ActionToken, error) {
+	var stored models.ActionToken
+
+	if result := tx.Where(&models.ActionToken{TokenHash: utils.HashToken(token), Purpose: purpose}).First(&stored); result.Error != nil {
+		return nil, errInvalidActionToken
+	}
+
+	if stored.UsedAt != nil || stored.ExpiresAt.Before(time.Now()) {
+		return nil, errInvalidActionToken
+	}
+
+	return &stored, nil
+}
+
+// consumeActionToken marks the token used. The conditional update makes sure
+// two concurrent requests can't both use it.
+func consumeActionToken(tx *gorm.DB, purpose string, token string) (*models.ActionToken, error) {
+	stored, err := findActionToken(tx, purpose, token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := tx.Model(&models.ActionToken{}).
+		Where("id = ? AND used_at IS NULL", stored.Id).
+		Update("used_at", time.Now())
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, errInvalidActionToken
+	}
+
+	return stored, nil
+}
+
+func (s *Server) link(path string, token string) string {
+	return s.AppURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// mailer falls back to logging for servers set up without one, like in tests.
+func (s *Server) mailer() mail.Mailer {
+	if s.Mailer == nil {
+		return &mail.LogMailer{}
+	}
+
+	return s.Mailer
+}
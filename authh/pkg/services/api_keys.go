@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"authh/pkg/models"
+	"authh/pkg/pb"
+	"authh/pkg/utils"
+)
+
+// apiKeyPrefix marks our keys so they are easy to spot in logs and by secret
+// scanners.
+const apiKeyPrefix = "sk_"
+
+func toAPIKeyData(key models.APIKey) *pb.APIKeyData {
+	data := &pb.APIKeyData{
+		Id:        key.Id,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.ScopeList(),
+		CreatedAt: key.CreatedAt.Unix(),
+		Revoked:   key.RevokedAt != nil,
+	}
+
+	if key.ExpiresAt != nil {
+		data.ExpiresAt = key.ExpiresAt.Unix()
+	}
+
+	if key.LastUsedAt != nil {
+		data.LastUsedAt = key.LastUsedAt.Unix()
+	}
+
+	return data
+}
+
+// generateAPIKey returns a key of the form sk_<prefix>_<secret> together with
+// the part that is stored in clear.
+func generateAPIKey() (string, string, error) {
+	b := make([]byte, 4)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	secret, err := utils.RandomToken(32)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	prefix := apiKeyPrefix + hex.EncodeToString(b)
+
+	return prefix + "_" + secret, prefix, nil
+}
+
+// CreateAPIKey issues a key for the user. Scopes are permissions and must be
+// held by the user.
+func (s *Server) CreateAPIKey(ctx context.Context, req *pb.CreateAPIKeyRequest) (*pb.CreateAPIKeyResponse, error) {
+	name := strings.TrimSpace(req.Name)
+
+	if name == "" {
+		return &pb.CreateAPIKeyResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Name is required",
+		}, nil
+	}
+
+	if len(req.Scopes) == 0 {
+		return &pb.CreateAPIKeyResponse{
+			Status: http.StatusBadRequest,
+			Error:  "At least one scope is required",
+		}, nil
+	}
+
+	if req.ExpiresIn < 0 {
+		return &pb.CreateAPIKeyResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Expiry must be positive",
+		}, nil
+	}
+
+	user, res := s.findUser(req.UserId)
+
+	if res != nil {
+		return &pb.CreateAPIKeyResponse{Status: res.Status, Error: res.Error}, nil
+	}
+
+	held := models.Permissions(user.RoleNames())
+
+	for _, scope := range req.Scopes {
+		if !models.IsPermission(scope) {
+			return &pb.CreateAPIKeyResponse{
+				Status: http.StatusBadRequest,
+				Error:  fmt.Sprintf("Unknown scope %q", scope),
+			}, nil
+		}
+
+		if !containsString(held, scope) {
+			return &pb.CreateAPIKeyResponse{
+				Status: http.StatusForbidden,
+				Error:  fmt.Sprintf("Scope %q is not granted to the user", scope),
+			}, nil
+		}
+	}
+
+	key, prefix, err := generateAPIKey()
+
+	if err != nil {
+		return &pb.CreateAPIKeyResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	apiKey := models.APIKey{
+		UserId:  user.Id,
+		Name:    name,
+		Prefix:  prefix,
+		KeyHash: utils.HashToken(key),
+		Scopes:  strings.Join(req.Scopes, " "),
+	}
+
+	if req.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresIn) * time.Second)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	if result := s.H.DB.Create(&apiKey); result.Error != nil {
+		return &pb.CreateAPIKeyResponse{
+			Status: http.StatusInternalServerError,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	return &pb.CreateAPIKeyResponse{
+		Status: http.StatusCreated,
+		Data:   toAPIKeyData(apiKey),
+		Key:    key,
+	}, nil
+}
+
+func (s *Server) ListAPIKeys(ctx context.Context, req *pb.ListAPIKeysRequest) (*pb.ListAPIKeysResponse, error) {
+	var keys []models.APIKey
+
+	if result := s.H.DB.Where("user_id = ?", req.UserId).Order("id").Find(&keys); result.Error != nil {
+		return &pb.ListAPIKeysResponse{
+			Status: http.StatusInternalServerError,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	data := make([]*pb.APIKeyData, 0, len(keys))
+
+	for _, key := range keys {
+		data = append(data, toAPIKeyData(key))
+	}
+
+	return &pb.ListAPIKeysResponse{
+		Status: http.StatusOK,
+		Keys:   data,
+	}, nil
+}
+
+// RevokeAPIKey only touches keys of req.UserId, so users cannot revoke each
+// other's keys by guessing ids.
+func (s *Server) RevokeAPIKey(ctx context.Context, req *pb.RevokeAPIKeyRequest) (*pb.RevokeAPIKeyResponse, error) {
+	var key models.APIKey
+
+	if result := s.H.DB.Where("id = ? AND user_id = ?", req.Id, req.UserId).First(&key); result.Error != nil {
+		return &pb.RevokeAPIKeyResponse{
+			Status: http.StatusNotFound,
+			Error:  "API key not found",
+		}, nil
+	}
+
+	if key.RevokedAt == nil {
+		if result := s.H.DB.Model(&key).Update("revoked_at", time.Now()); result.Error != nil {
+			return &pb.RevokeAPIKeyResponse{
+				Status: http.StatusInternalServerError,
+				Error:  result.Error.Error(),
+			}, nil
+		}
+	}
+
+	// Nothing to remember past this point since revoked keys fail Validate;
+	// the notice only clears caches.
+	s.revocations.publish(&pb.RevocationNotice{Jti: apiKeyJti(key.Id), ExpiresAt: time.Now().Unix()})
+
+	return &pb.RevokeAPIKeyResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+// validateAPIKey answers Validate for an API key. The permissions are the
+// scopes of the key that its owner still holds, so taking a role away from
+// the owner narrows the key as well.
+func (s *Server) validateAPIKey(key string) *pb.ValidateResponse {
+	var apiKey models.APIKey
+
+	if result := s.H.DB.Where(&models.APIKey{KeyHash: utils.HashToken(key)}).First(&apiKey); result.Error != nil {
+		return &pb.ValidateResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Invalid API key",
+		}
+	}
+
+	if apiKey.RevokedAt != nil {
+		return &pb.ValidateResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "API key has been revoked",
+		}
+	}
+
+	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(time.Now()) {
+		return &pb.ValidateResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "API key has expired",
+		}
+	}
+
+	var user models.User
+
+	if result := s.H.DB.Preload("Roles").First(&user, apiKey.UserId); result.Error != nil {
+		return &pb.ValidateResponse{
+			Status: http.StatusNotFound,
+			Error:  "User not found",
+		}
+	}
+
+	if user.Disabled {
+		return &pb.ValidateResponse{
+			Status: http.StatusForbidden,
+			Error:  accountDisabled,
+		}
+	}
+
+	held := models.Permissions(user.RoleNames())
+	var permissions []string
+
+	for _, scope := range apiKey.ScopeList() {
+		if containsString(held, scope) {
+			permissions = append(permissions, scope)
+		}
+	}
+
+	if result := s.H.DB.Model(&apiKey).UpdateColumn("last_used_at", time.Now()); result.Error != nil {
+		log.Println("Failed to record API key use:", result.Error)
+	}
+
+	return &pb.ValidateResponse{
+		Status:      http.StatusOK,
+		UserId:      user.Id,
+		Permissions: permissions,
+		ApiKeyId:    apiKey.Id,
+	}
+}
+
+// apiKeyJti names an API key in revocation notices.
+func apiKeyJti(id int64) string {
+	return fmt.Sprintf("api-key:%d", id)
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
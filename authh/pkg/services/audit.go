@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"authh/pkg/models"
+	"authh/pkg/pb"
+	"google.golang.org/grpc"
+)
+
+const (
+	defaultEventPageSize = 50
+	maxEventPageSize     = 500
+)
+
+// auditedMethods maps the RPCs written to the audit log to their event type.
+// Reads like GetMe or ListUsers are left out.
+var auditedMethods = map[string]string{
+	"Register":             "register",
+	"Login":                "login",
+	"Validate":             "validate",
+	"Refresh":              "refresh",
+	"Logout":               "logout",
+	"AssignRoles":          "assign_roles",
+	"RequestPasswordReset": "request_password_reset",
+	"ResetPassword":        "reset_password",
+	"SendVerification":     "send_verification",
+	"VerifyEmail":          "verify_email",
+	"Unlock":               "unlock",
+	"EnrollTOTP":           "enroll_totp",
+	"ConfirmTOTP":          "confirm_totp",
+	"DisableTOTP":          "disable_totp",
+	"VerifySecondFactor":   "verify_second_factor",
+	"UpdateMe":             "update_profile",
+	"ChangePassword":       "change_password",
+	"ChangeEmail":          "change_email",
+	"DisableUser":          "disable_user",
+	"EnableUser":           "enable_user",
+	"DeleteUser":           "delete_user",
+	"CreateAPIKey":         "create_api_key",
+	"RevokeAPIKey":         "revoke_api_key",
+	"RegisterOAuthClient":  "register_oauth_client",
+	"DeleteOAuthClient":    "delete_oauth_client",
+	"OAuthAuthorize":       "oauth_authorize",
+	"OAuthToken":           "oauth_token",
+	"OAuthRevoke":          "oauth_revoke",
+}
+
+type auditContextKey struct{}
+
+type eventCursor struct {
+	Id int64 `json:"id"`
+}
+
+// AuditInterceptor writes an auth event for every audited RPC. The user is
+// taken from the request or response where they carry one; handlers that
+// only find out along the way report it with auditUser. The actor is the
+// caller the gateway authenticated, which for admin actions is not the user.
+func (s *Server) AuditInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	eventType, ok := auditedMethods[path.Base(info.FullMethod)]
+
+	if !ok {
+		return handler(ctx, req)
+	}
+
+	event := &models.AuthEvent{
+		Type:      eventType,
+		IP:        clientIP(ctx),
+		UserAgent: incomingMetadata(ctx, UserAgentMetadataKey),
+	}
+
+	event.ActorId, _ = strconv.ParseInt(incomingMetadata(ctx, UserIdMetadataKey), 10, 64)
+
+	if r, ok := req.(interface{ GetUserId() int64 }); ok {
+		event.UserId = r.GetUserId()
+	}
+
+	if r, ok := req.(interface{ GetEmail() string }); ok {
+		event.Email = r.GetEmail()
+	}
+
+	res, err := handler(context.WithValue(ctx, auditContextKey{}, event), req)
+
+	if r, ok := res.(interface{ GetUserId() int64 }); ok && event.UserId == 0 {
+		event.UserId = r.GetUserId()
+	}
+
+	if r, ok := res.(interface{ GetStatus() int64 }); ok {
+		event.Status = r.GetStatus()
+	}
+
+	if r, ok := res.(interface{ GetError() string }); ok {
+		event.Detail = r.GetError()
+	}
+
+	event.Result = models.AuthEventSuccess
+
+	if err != nil || event.Status >= http.StatusBadRequest {
+		event.Result = models.AuthEventFailure
+	}
+
+	if err != nil {
+		event.Detail = err.Error()
+	}
+
+	if result := s.H.DB.Create(event); result.Error != nil {
+		log.Println("Failed to write auth event:", result.Error)
+	}
+
+	return res, err
+}
+
+// auditUser names the user of the event being recorded for ctx.
+func auditUser(ctx context.Context, userId int64) {
+	if event, ok := ctx.Value(auditContextKey{}).(*models.AuthEvent); ok {
+		event.UserId = userId
+	}
+}
+
+// ListAuthEvents pages through the audit log. Callers are expected to have
+// checked that the requester is an admin.
+func (s *Server) ListAuthEvents(ctx context.Context, req *pb.ListAuthEventsRequest) (*pb.ListAuthEventsResponse, error) {
+	pageSize := int(req.PageSize)
+
+	if pageSize <= 0 {
+		pageSize = defaultEventPageSize
+	}
+
+	if pageSize > maxEventPageSize {
+		pageSize = maxEventPageSize
+	}
+
+	query := s.H.DB.Order("id DESC")
+
+	if req.UserId != 0 {
+		query = query.Where("user_id = ?", req.UserId)
+	}
+
+	if req.ActorId != 0 {
+		query = query.Where("actor_id = ?", req.ActorId)
+	}
+
+	if req.Type != "" {
+		query = query.Where("type = ?", req.Type)
+	}
+
+	if req.Since != "" {
+		since, err := time.Parse(time.RFC3339, req.Since)
+
+		if err != nil {
+			return &pb.ListAuthEventsResponse{
+				Status: http.StatusBadRequest,
+				Error:  "since must be an RFC 3339 timestamp",
+			}, nil
+		}
+
+		query = query.Where("created_at >= ?", since)
+	}
+
+	if req.Until != "" {
+		until, err := time.Parse(time.RFC3339, req.Until)
+
+		if err != nil {
+			return &pb.ListAuthEventsResponse{
+				Status: http.StatusBadRequest,
+				Error:  "until must be an RFC 3339 timestamp",
+			}, nil
+		}
+
+		query = query.Where("created_at < ?", until)
+	}
+
+	if req.PageToken != "" {
+		var cursor eventCursor
+
+		raw, err := base64.RawURLEncoding.DecodeString(req.PageToken)
+
+		if err == nil {
+			err = json.Unmarshal(raw, &cursor)
+		}
+
+		if err != nil {
+			return &pb.ListAuthEventsResponse{
+				Status: http.StatusBadRequest,
+				Error:  "Invalid page token",
+			}, nil
+		}
+
+		query = query.Where("id < ?", cursor.Id)
+	}
+
+	var events []models.AuthEvent
+
+	if result := query.Limit(pageSize + 1).Find(&events); result.Error != nil {
+		return &pb.ListAuthEventsResponse{
+			Status: http.StatusInternalServerError,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	res := &pb.ListAuthEventsResponse{
+		Status: http.StatusOK,
+	}
+
+	if len(events) > pageSize {
+		events = events[:pageSize]
+
+		raw, _ := json.Marshal(eventCursor{Id: events[pageSize-1].Id})
+		res.NextPageToken = base64.RawURLEncoding.EncodeToString(raw)
+	}
+
+	for _, event := range events {
+		res.Events = append(res.Events, &pb.AuthEventData{
+			Id:        event.Id,
+			Type:      event.Type,
+			UserId:    event.UserId,
+			ActorId:   event.ActorId,
+			Email:     event.Email,
+			Ip:        event.IP,
+			UserAgent: event.UserAgent,
+			Result:    event.Result,
+			Status:    event.Status,
+			Detail:    event.Detail,
+			CreatedAt: event.CreatedAt.Unix(),
+		})
+	}
+
+	return res, nil
+}
@@ -2,17 +2,38 @@ package services
 
 import (
 	"context"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"authh/pkg/db"
+	"authh/pkg/mail"
 	"authh/pkg/models"
 	"authh/pkg/pb"
 	"authh/pkg/utils"
 )
 
+const accountDisabled = "Account disabled"
+
 type Server struct {
-	H   db.Handler
-	Jwt utils.JwtWrapper
+	H               db.Handler
+	Jwt             utils.JwtWrapper
+	RefreshTokenTTL time.Duration
+	AdminEmail      string
+	Mailer          mail.Mailer
+	AppURL          string
+	ResetTokenTTL   time.Duration
+	VerifyTokenTTL  time.Duration
+	Limits          LoginLimits
+	ResetLimits     ResetLimits
+	TOTPIssuer      string
+	Hasher          *utils.PasswordHasher
+	Policy          utils.PasswordPolicy
+	OAuth           OAuthSettings
+	EventRetention  time.Duration
+
+	revocations revocationHub
 }
 
 func (s *Server) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
@@ -25,44 +46,149 @@ func (s *Server) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.Reg
 		}, nil
 	}
 
+	if err := s.Policy.Check(req.Password, req.Email); err != nil {
+		return &pb.RegisterResponse{
+			Status: http.StatusBadRequest,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	hash, err := s.hasher().Hash(req.Password)
+
+	if err != nil {
+		return &pb.RegisterResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	names := []string{models.RoleUser}
+
+	// The configured bootstrap account becomes the first admin; every other
+	// admin is granted through AssignRoles.
+	if s.AdminEmail != "" && req.Email == s.AdminEmail {
+		names = append(names, models.RoleAdmin)
+	}
+
+	roles, err := s.findRoles(names)
+
+	if err != nil {
+		return &pb.RegisterResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
 	user.Email = req.Email
-	user.Password = utils.HashPassword(req.Password)
+	user.Password = hash
+	user.Roles = roles
 
 	s.H.DB.Create(&user)
 
+	auditUser(ctx, user.Id)
+
+	if err := s.sendVerification(user); err != nil {
+		log.Println("Failed to send verification:", err)
+	}
+
 	return &pb.RegisterResponse{
 		Status: http.StatusCreated,
 	}, nil
 }
 
 func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	ip := clientIP(ctx)
+
+	if delay := s.loginDelay(req.Email, ip); delay > 0 {
+		return &pb.LoginResponse{
+			Status: http.StatusTooManyRequests,
+			Error:  tooManyAttempts(delay),
+		}, nil
+	}
+
 	var user models.User
 
-	if result := s.H.DB.Where(&models.User{Email: req.Email}).First(&user); result.Error != nil {
+	if result := s.H.DB.Preload("Roles").Where(&models.User{Email: req.Email}).First(&user); result.Error != nil {
+		s.recordLoginFailure(req.Email, ip)
+
 		return &pb.LoginResponse{
 			Status: http.StatusNotFound,
 			Error:  "User not found",
 		}, nil
 	}
 
-	match := utils.CheckPasswordHash(req.Password, user.Password)
+	auditUser(ctx, user.Id)
+
+	if !s.checkPassword(&user, req.Password) {
+		s.recordLoginFailure(req.Email, ip)
 
-	if !match {
 		return &pb.LoginResponse{
 			Status: http.StatusNotFound,
 			Error:  "User not found",
 		}, nil
 	}
 
-	token, _ := s.Jwt.GenerateToken(user)
+	if user.Disabled {
+		return &pb.LoginResponse{
+			Status: http.StatusForbidden,
+			Error:  accountDisabled,
+		}, nil
+	}
+
+	// Failures are only forgiven once the second factor is right too, or the
+	// password alone would allow unlimited guesses at the code.
+	if user.TOTPEnabled {
+		challenge, err := createActionToken(s.H.DB, user.Id, models.PurposeLoginChallenge, challengeTTL)
+
+		if err != nil {
+			return &pb.LoginResponse{
+				Status: http.StatusInternalServerError,
+				Error:  err.Error(),
+			}, nil
+		}
+
+		return &pb.LoginResponse{
+			Status:               http.StatusAccepted,
+			SecondFactorRequired: true,
+			ChallengeToken:       challenge,
+		}, nil
+	}
+
+	s.resetLoginFailures(req.Email)
+
+	return s.completeLogin(user)
+}
+
+func (s *Server) completeLogin(user models.User) (*pb.LoginResponse, error) {
+	if result := s.H.DB.Model(&user).UpdateColumn("last_login_at", time.Now()); result.Error != nil {
+		log.Println("Failed to record login:", result.Error)
+	}
+
+	token, refreshToken, err := s.issueTokens(user, "")
+
+	if err != nil {
+		return &pb.LoginResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
 
 	return &pb.LoginResponse{
-		Status: http.StatusOK,
-		Token:  token,
+		Status:       http.StatusOK,
+		Token:        token,
+		RefreshToken: refreshToken,
 	}, nil
 }
 
 func (s *Server) Validate(ctx context.Context, req *pb.ValidateRequest) (*pb.ValidateResponse, error) {
+	if req.ApiKey != "" {
+		return s.validateAPIKey(req.ApiKey), nil
+	}
+
+	if strings.HasPrefix(req.Token, oauthAccessTokenPrefix) {
+		return s.validateOAuthToken(req.Token), nil
+	}
+
 	claims, err := s.Jwt.ValidateToken(req.Token)
 
 	if err != nil {
@@ -72,17 +198,38 @@ func (s *Server) Validate(ctx context.Context, req *pb.ValidateRequest) (*pb.Val
 		}, nil
 	}
 
+	if s.isRevoked(claims.StandardClaims.Id) {
+		return &pb.ValidateResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Token has been revoked",
+		}, nil
+	}
+
 	var user models.User
 
-	if result := s.H.DB.Where(&models.User{Email: claims.Email}).First(&user); result.Error != nil {
+	// Looked up by id, which unlike the e-mail can't change.
+	if result := s.H.DB.Preload("Roles").First(&user, claims.Id); result.Error != nil {
 		return &pb.ValidateResponse{
 			Status: http.StatusNotFound,
 			Error:  "User not found",
 		}, nil
 	}
 
+	if user.Disabled {
+		return &pb.ValidateResponse{
+			Status: http.StatusForbidden,
+			Error:  accountDisabled,
+		}, nil
+	}
+
+	// Roles come from the database rather than the claims so that a change
+	// takes effect before the token expires.
+	roles := user.RoleNames()
+
 	return &pb.ValidateResponse{
-		Status: http.StatusOK,
-		UserId: user.Id,
+		Status:      http.StatusOK,
+		UserId:      user.Id,
+		Roles:       roles,
+		Permissions: models.Permissions(roles),
 	}, nil
 }
@@ -0,0 +1,17 @@
+package services
+
+import (
+	"context"
+	"net/http"
+
+	"authh/pkg/pb"
+)
+
+// GetJWKS publishes the public keys tokens are signed with so that other
+// services can verify them without calling Validate.
+func (s *Server) GetJWKS(ctx context.Context, req *pb.GetJWKSRequest) (*pb.GetJWKSResponse, error) {
+	return &pb.GetJWKSResponse{
+		Status: http.StatusOK,
+		Keys:   s.Jwt.Keys.JWKS(),
+	}, nil
+}
@@ -0,0 +1,854 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"authh/pkg/models"
+	"authh/pkg/pb"
+	"authh/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	oauthAccessTokenPrefix  = "oat_"
+	oauthRefreshTokenPrefix = "ort_"
+)
+
+// Error codes from RFC 6749.
+const (
+	oauthInvalidRequest          = "invalid_request"
+	oauthInvalidClient           = "invalid_client"
+	oauthInvalidGrant            = "invalid_grant"
+	oauthInvalidScope            = "invalid_scope"
+	oauthUnauthorizedClient      = "unauthorized_client"
+	oauthUnsupportedGrantType    = "unsupported_grant_type"
+	oauthUnsupportedResponseType = "unsupported_response_type"
+	oauthAccessDenied            = "access_denied"
+)
+
+var errOAuthGrantUsed = errors.New("Grant has already been used")
+
+// codeVerifierPattern is the code_verifier syntax from RFC 7636.
+var codeVerifierPattern = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+
+type OAuthSettings struct {
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	CodeTTL         time.Duration
+}
+
+// RegisterOAuthClient registers an application owned by the user. Like API
+// keys, its scopes must be permissions the owner holds.
+func (s *Server) RegisterOAuthClient(ctx context.Context, req *pb.RegisterOAuthClientRequest) (*pb.RegisterOAuthClientResponse, error) {
+	name := strings.TrimSpace(req.Name)
+
+	if name == "" {
+		return &pb.RegisterOAuthClientResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Name is required",
+		}, nil
+	}
+
+	if len(req.Scopes) == 0 {
+		return &pb.RegisterOAuthClientResponse{
+			Status: http.StatusBadRequest,
+			Error:  "At least one scope is required",
+		}, nil
+	}
+
+	// Public clients can only use the authorization code grant, which needs
+	// somewhere to send the code.
+	if !req.Confidential && len(req.RedirectUris) == 0 {
+		return &pb.RegisterOAuthClientResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Public clients need a redirect URI",
+		}, nil
+	}
+
+	for _, uri := range req.RedirectUris {
+		if err := checkRedirectURI(uri); err != nil {
+			return &pb.RegisterOAuthClientResponse{
+				Status: http.StatusBadRequest,
+				Error:  err.Error(),
+			}, nil
+		}
+	}
+
+	user, res := s.findUser(req.UserId)
+
+	if res != nil {
+		return &pb.RegisterOAuthClientResponse{Status: res.Status, Error: res.Error}, nil
+	}
+
+	held := models.Permissions(user.RoleNames())
+
+	for _, scope := range req.Scopes {
+		if !models.IsPermission(scope) {
+			return &pb.RegisterOAuthClientResponse{
+				Status: http.StatusBadRequest,
+				Error:  "Unknown scope " + strconv.Quote(scope),
+			}, nil
+		}
+
+		if !containsString(held, scope) {
+			return &pb.RegisterOAuthClientResponse{
+				Status: http.StatusForbidden,
+				Error:  "Scope " + strconv.Quote(scope) + " is not granted to the user",
+			}, nil
+		}
+	}
+
+	clientId, err := utils.RandomToken(16)
+
+	var secret string
+
+	if err == nil && req.Confidential {
+		secret, err = utils.RandomToken(32)
+	}
+
+	if err != nil {
+		return &pb.RegisterOAuthClientResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	client := models.OAuthClient{
+		ClientId:     clientId,
+		Name:         name,
+		OwnerId:      user.Id,
+		RedirectURIs: strings.Join(req.RedirectUris, " "),
+		Scopes:       strings.Join(req.Scopes, " "),
+		Confidential: req.Confidential,
+	}
+
+	if secret != "" {
+		client.SecretHash = utils.HashToken(secret)
+	}
+
+	if result := s.H.DB.Create(&client); result.Error != nil {
+		return &pb.RegisterOAuthClientResponse{
+			Status: http.StatusInternalServerError,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	return &pb.RegisterOAuthClientResponse{
+		Status:       http.StatusCreated,
+		ClientId:     clientId,
+		ClientSecret: secret,
+	}, nil
+}
+
+// DeleteOAuthClient removes a client of the user along with every token
+// issued to it.
+func (s *Server) DeleteOAuthClient(ctx context.Context, req *pb.DeleteOAuthClientRequest) (*pb.DeleteOAuthClientResponse, error) {
+	var client models.OAuthClient
+
+	if result := s.H.DB.Where("client_id = ? AND owner_id = ?", req.ClientId, req.UserId).First(&client); result.Error != nil {
+		return &pb.DeleteOAuthClientResponse{
+			Status: http.StatusNotFound,
+			Error:  "Client not found",
+		}, nil
+	}
+
+	var tokens []models.OAuthToken
+
+	err := s.H.DB.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Where("client_id = ? AND kind = ? AND revoked_at IS NULL AND expires_at > ?", client.ClientId, models.OAuthTokenAccess, time.Now()).Find(&tokens); result.Error != nil {
+			return result.Error
+		}
+
+		for _, model := range []interface{}{&models.OAuthToken{}, &models.OAuthCode{}} {
+			if result := tx.Where("client_id = ?", client.ClientId).Delete(model); result.Error != nil {
+				return result.Error
+			}
+		}
+
+		return tx.Delete(&client).Error
+	})
+
+	if err != nil {
+		return &pb.DeleteOAuthClientResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	for _, token := range tokens {
+		s.publishOAuthRevocation(token)
+	}
+
+	return &pb.DeleteOAuthClientResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+// OAuthAuthorize issues an authorization code once the user has approved the
+// client; asking the user is up to the caller. Only the S256 PKCE method is
+// accepted, for confidential clients too.
+func (s *Server) OAuthAuthorize(ctx context.Context, req *pb.OAuthAuthorizeRequest) (*pb.OAuthAuthorizeResponse, error) {
+	if req.ClientId == "" {
+		return &pb.OAuthAuthorizeResponse{
+			Status:           http.StatusBadRequest,
+			Error:            oauthInvalidRequest,
+			ErrorDescription: "Client id is required",
+		}, nil
+	}
+
+	var client models.OAuthClient
+
+	if result := s.H.DB.Where("client_id = ?", req.ClientId).First(&client); result.Error != nil {
+		return &pb.OAuthAuthorizeResponse{
+			Status:           http.StatusBadRequest,
+			Error:            oauthInvalidClient,
+			ErrorDescription: "Unknown client",
+		}, nil
+	}
+
+	redirectURI := req.RedirectUri
+	registered := client.RedirectURIList()
+
+	if redirectURI == "" && len(registered) == 1 {
+		redirectURI = registered[0]
+	}
+
+	// Errors can only be sent to a redirect URI that was registered.
+	if !containsString(registered, redirectURI) {
+		return &pb.OAuthAuthorizeResponse{
+			Status:           http.StatusBadRequest,
+			Error:            oauthInvalidRequest,
+			ErrorDescription: "Redirect URI is not registered for the client",
+		}, nil
+	}
+
+	if req.ResponseType != "code" {
+		return authorizeError(redirectURI, req.State, oauthUnsupportedResponseType, "Only the code response type is supported"), nil
+	}
+
+	if req.CodeChallenge == "" {
+		return authorizeError(redirectURI, req.State, oauthInvalidRequest, "PKCE code challenge is required"), nil
+	}
+
+	if req.CodeChallengeMethod != "S256" {
+		return authorizeError(redirectURI, req.State, oauthInvalidRequest, "Code challenge method must be S256"), nil
+	}
+
+	user, res := s.findUser(req.UserId)
+
+	if res != nil || user.Disabled {
+		return authorizeError(redirectURI, req.State, oauthAccessDenied, "User cannot authorize clients"), nil
+	}
+
+	scopes, ok := grantScopes(client, req.Scope, *user)
+
+	if !ok {
+		return authorizeError(redirectURI, req.State, oauthInvalidScope, "Requested scope is not allowed"), nil
+	}
+
+	code, err := utils.RandomToken(32)
+
+	if err == nil {
+		err = s.H.DB.Create(&models.OAuthCode{
+			CodeHash:      utils.HashToken(code),
+			ClientId:      client.ClientId,
+			UserId:        user.Id,
+			RedirectURI:   req.RedirectUri,
+			Scopes:        strings.Join(scopes, " "),
+			CodeChallenge: req.CodeChallenge,
+			ExpiresAt:     time.Now().Add(s.OAuth.CodeTTL),
+		}).Error
+	}
+
+	if err != nil {
+		return &pb.OAuthAuthorizeResponse{
+			Status:           http.StatusInternalServerError,
+			Error:            "server_error",
+			ErrorDescription: err.Error(),
+		}, nil
+	}
+
+	return &pb.OAuthAuthorizeResponse{
+		Status:      http.StatusFound,
+		RedirectUri: withQuery(redirectURI, map[string]string{"code": code, "state": req.State}),
+	}, nil
+}
+
+func (s *Server) OAuthToken(ctx context.Context, req *pb.OAuthTokenRequest) (*pb.OAuthTokenResponse, error) {
+	switch req.GrantType {
+	case "client_credentials":
+		return s.clientCredentialsGrant(ctx, req), nil
+	case "authorization_code":
+		return s.authorizationCodeGrant(ctx, req), nil
+	case "refresh_token":
+		return s.refreshTokenGrant(ctx, req), nil
+	}
+
+	return tokenError(http.StatusBadRequest, oauthUnsupportedGrantType, "Unsupported grant type"), nil
+}
+
+// clientCredentialsGrant lets a confidential client act as its owner.
+func (s *Server) clientCredentialsGrant(ctx context.Context, req *pb.OAuthTokenRequest) *pb.OAuthTokenResponse {
+	client, ok := s.authenticateClient(req.ClientId, req.ClientSecret)
+
+	if !ok {
+		return tokenError(http.StatusUnauthorized, oauthInvalidClient, "Client authentication failed")
+	}
+
+	if !client.Confidential {
+		return tokenError(http.StatusBadRequest, oauthUnauthorizedClient, "Public clients cannot use client credentials")
+	}
+
+	auditUser(ctx, client.OwnerId)
+
+	owner, res := s.findUser(client.OwnerId)
+
+	if res != nil || owner.Disabled {
+		return tokenError(http.StatusBadRequest, oauthUnauthorizedClient, "Client owner is not active")
+	}
+
+	scopes, ok := grantScopes(*client, req.Scope, *owner)
+
+	if !ok {
+		return tokenError(http.StatusBadRequest, oauthInvalidScope, "Requested scope is not allowed")
+	}
+
+	grantId, err := utils.RandomToken(16)
+
+	if err != nil {
+		return tokenError(http.StatusInternalServerError, "server_error", err.Error())
+	}
+
+	// RFC 6749 4.4.3: no refresh token for client credentials.
+	tokens, err := s.issueOAuthTokens(s.H.DB, client.ClientId, owner.Id, scopes, grantId, false)
+
+	if err != nil {
+		return tokenError(http.StatusInternalServerError, "server_error", err.Error())
+	}
+
+	return tokens
+}
+
+func (s *Server) authorizationCodeGrant(ctx context.Context, req *pb.OAuthTokenRequest) *pb.OAuthTokenResponse {
+	client, ok := s.authenticateClient(req.ClientId, req.ClientSecret)
+
+	if !ok {
+		return tokenError(http.StatusUnauthorized, oauthInvalidClient, "Client authentication failed")
+	}
+
+	var code models.OAuthCode
+
+	if result := s.H.DB.Where(&models.OAuthCode{CodeHash: utils.HashToken(req.Code), ClientId: client.ClientId}).First(&code); result.Error != nil {
+		return tokenError(http.StatusBadRequest, oauthInvalidGrant, "Invalid authorization code")
+	}
+
+	// A code presented twice has probably been intercepted, so whatever the
+	// first exchange returned is revoked (RFC 6749 4.1.2).
+	if code.UsedAt != nil {
+		s.revokeOAuthGrant(code.GrantId)
+		return tokenError(http.StatusBadRequest, oauthInvalidGrant, "Authorization code has already been used")
+	}
+
+	if code.ExpiresAt.Before(time.Now()) {
+		return tokenError(http.StatusBadRequest, oauthInvalidGrant, "Authorization code has expired")
+	}
+
+	if req.RedirectUri != code.RedirectURI {
+		return tokenError(http.StatusBadRequest, oauthInvalidGrant, "Redirect URI does not match")
+	}
+
+	if !verifyCodeChallenge(code.CodeChallenge, req.CodeVerifier) {
+		return tokenError(http.StatusBadRequest, oauthInvalidGrant, "Code verifier does not match")
+	}
+
+	auditUser(ctx, code.UserId)
+
+	user, res := s.findUser(code.UserId)
+
+	if res != nil || user.Disabled {
+		return tokenError(http.StatusBadRequest, oauthInvalidGrant, "User is not active")
+	}
+
+	grantId, err := utils.RandomToken(16)
+
+	if err != nil {
+		return tokenError(http.StatusInternalServerError, "server_error", err.Error())
+	}
+
+	var tokens *pb.OAuthTokenResponse
+
+	err = s.H.DB.Transaction(func(tx *gorm.DB) error {
+		// Conditional, so that of two concurrent exchanges only one wins.
+		result := tx.Model(&models.OAuthCode{}).
+			Where("id = ? AND used_at IS NULL", code.Id).
+			Updates(map[string]interface{}{"used_at": time.Now(), "grant_id": grantId})
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errOAuthGrantUsed
+		}
+
+		var err error
+		tokens, err = s.issueOAuthTokens(tx, client.ClientId, user.Id, strings.Fields(code.Scopes), grantId, true)
+
+		return err
+	})
+
+	if errors.Is(err, errOAuthGrantUsed) {
+		return tokenError(http.StatusBadRequest, oauthInvalidGrant, "Authorization code has already been used")
+	}
+
+	if err != nil {
+		return tokenError(http.StatusInternalServerError, "server_error", err.Error())
+	}
+
+	return tokens
+}
+
+// refreshTokenGrant rotates the refresh token. The scope may be narrowed but
+// never widened.
+func (s *Server) refreshTokenGrant(ctx context.Context, req *pb.OAuthTokenRequest) *pb.OAuthTokenResponse {
+	client, ok := s.authenticateClient(req.ClientId, req.ClientSecret)
+
+	if !ok {
+		return tokenError(http.StatusUnauthorized, oauthInvalidClient, "Client authentication failed")
+	}
+
+	var stored models.OAuthToken
+
+	if result := s.H.DB.Where(&models.OAuthToken{TokenHash: utils.HashToken(req.RefreshToken), Kind: models.OAuthTokenRefresh, ClientId: client.ClientId}).First(&stored); result.Error != nil {
+		return tokenError(http.StatusBadRequest, oauthInvalidGrant, "Invalid refresh token")
+	}
+
+	if stored.RevokedAt != nil {
+		// Same as for our own refresh tokens: reuse means a leak.
+		s.revokeOAuthGrant(stored.GrantId)
+		return tokenError(http.StatusBadRequest, oauthInvalidGrant, "Refresh token has been revoked")
+	}
+
+	if stored.ExpiresAt.Before(time.Now()) {
+		return tokenError(http.StatusBadRequest, oauthInvalidGrant, "Refresh token has expired")
+	}
+
+	scopes := stored.ScopeList()
+
+	if requested := strings.Fields(req.Scope); len(requested) > 0 {
+		for _, scope := range requested {
+			if !containsString(scopes, scope) {
+				return tokenError(http.StatusBadRequest, oauthInvalidScope, "Requested scope exceeds the original grant")
+			}
+		}
+
+		scopes = requested
+	}
+
+	auditUser(ctx, stored.UserId)
+
+	user, res := s.findUser(stored.UserId)
+
+	if res != nil || user.Disabled {
+		return tokenError(http.StatusBadRequest, oauthInvalidGrant, "User is not active")
+	}
+
+	var tokens *pb.OAuthTokenResponse
+
+	err := s.H.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.OAuthToken{}).
+			Where("id = ? AND revoked_at IS NULL", stored.Id).
+			Update("revoked_at", time.Now())
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errOAuthGrantUsed
+		}
+
+		var err error
+		tokens, err = s.issueOAuthTokens(tx, client.ClientId, user.Id, scopes, stored.GrantId, true)
+
+		return err
+	})
+
+	if errors.Is(err, errOAuthGrantUsed) {
+		s.revokeOAuthGrant(stored.GrantId)
+		return tokenError(http.StatusBadRequest, oauthInvalidGrant, "Refresh token has been revoked")
+	}
+
+	if err != nil {
+		return tokenError(http.StatusInternalServerError, "server_error", err.Error())
+	}
+
+	return tokens
+}
+
+// OAuthIntrospect implements RFC 7662 for the client the token was issued
+// to. Tokens of other clients are reported as inactive.
+func (s *Server) OAuthIntrospect(ctx context.Context, req *pb.OAuthIntrospectRequest) (*pb.OAuthIntrospectResponse, error) {
+	client, ok := s.authenticateClient(req.ClientId, req.ClientSecret)
+
+	if !ok {
+		return &pb.OAuthIntrospectResponse{
+			Status:           http.StatusUnauthorized,
+			Error:            oauthInvalidClient,
+			ErrorDescription: "Client authentication failed",
+		}, nil
+	}
+
+	inactive := &pb.OAuthIntrospectResponse{
+		Status: http.StatusOK,
+	}
+
+	var token models.OAuthToken
+
+	if result := s.H.DB.Where(&models.OAuthToken{TokenHash: utils.HashToken(req.Token), ClientId: client.ClientId}).First(&token); result.Error != nil {
+		return inactive, nil
+	}
+
+	if token.RevokedAt != nil || token.ExpiresAt.Before(time.Now()) {
+		return inactive, nil
+	}
+
+	if user, res := s.findUser(token.UserId); res != nil || user.Disabled {
+		return inactive, nil
+	}
+
+	res := &pb.OAuthIntrospectResponse{
+		Status:   http.StatusOK,
+		Active:   true,
+		Scope:    token.Scopes,
+		ClientId: token.ClientId,
+		Sub:      strconv.FormatInt(token.UserId, 10),
+		Exp:      token.ExpiresAt.Unix(),
+		Iat:      token.CreatedAt.Unix(),
+	}
+
+	if token.Kind == models.OAuthTokenAccess {
+		res.TokenType = "Bearer"
+	}
+
+	return res, nil
+}
+
+// OAuthRevoke implements RFC 7009. Revoking a refresh token ends the whole
+// grant. Unknown tokens are not an error.
+func (s *Server) OAuthRevoke(ctx context.Context, req *pb.OAuthRevokeRequest) (*pb.OAuthRevokeResponse, error) {
+	client, ok := s.authenticateClient(req.ClientId, req.ClientSecret)
+
+	if !ok {
+		return &pb.OAuthRevokeResponse{
+			Status:           http.StatusUnauthorized,
+			Error:            oauthInvalidClient,
+			ErrorDescription: "Client authentication failed",
+		}, nil
+	}
+
+	var token models.OAuthToken
+
+	if result := s.H.DB.Where(&models.OAuthToken{TokenHash: utils.HashToken(req.Token), ClientId: client.ClientId}).First(&token); result.Error != nil {
+		return &pb.OAuthRevokeResponse{
+			Status: http.StatusOK,
+		}, nil
+	}
+
+	if token.Kind == models.OAuthTokenRefresh {
+		s.revokeOAuthGrant(token.GrantId)
+	} else if token.RevokedAt == nil {
+		if result := s.H.DB.Model(&token).Update("revoked_at", time.Now()); result.Error != nil {
+			return &pb.OAuthRevokeResponse{
+				Status:           http.StatusServiceUnavailable,
+				Error:            "server_error",
+				ErrorDescription: result.Error.Error(),
+			}, nil
+		}
+
+		s.publishOAuthRevocation(token)
+	}
+
+	return &pb.OAuthRevokeResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+// validateOAuthToken answers Validate for an OAuth access token.
+func (s *Server) validateOAuthToken(token string) *pb.ValidateResponse {
+	var stored models.OAuthToken
+
+	if result := s.H.DB.Where(&models.OAuthToken{TokenHash: utils.HashToken(token), Kind: models.OAuthTokenAccess}).First(&stored); result.Error != nil {
+		return &pb.ValidateResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Invalid access token",
+		}
+	}
+
+	if stored.RevokedAt != nil {
+		return &pb.ValidateResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Token has been revoked",
+		}
+	}
+
+	if stored.ExpiresAt.Before(time.Now()) {
+		return &pb.ValidateResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Token has expired",
+		}
+	}
+
+	var user models.User
+
+	if result := s.H.DB.Preload("Roles").First(&user, stored.UserId); result.Error != nil {
+		return &pb.ValidateResponse{
+			Status: http.StatusNotFound,
+			Error:  "User not found",
+		}
+	}
+
+	if user.Disabled {
+		return &pb.ValidateResponse{
+			Status: http.StatusForbidden,
+			Error:  accountDisabled,
+		}
+	}
+
+	held := models.Permissions(user.RoleNames())
+	var permissions []string
+
+	for _, scope := range stored.ScopeList() {
+		if containsString(held, scope) {
+			permissions = append(permissions, scope)
+		}
+	}
+
+	return &pb.ValidateResponse{
+		Status:      http.StatusOK,
+		UserId:      user.Id,
+		Permissions: permissions,
+		ClientId:    stored.ClientId,
+	}
+}
+
+func (s *Server) issueOAuthTokens(tx *gorm.DB, clientId string, userId int64, scopes []string, grantId string, withRefresh bool) (*pb.OAuthTokenResponse, error) {
+	secret, err := utils.RandomToken(32)
+
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken := oauthAccessTokenPrefix + secret
+
+	access := models.OAuthToken{
+		TokenHash: utils.HashToken(accessToken),
+		Kind:      models.OAuthTokenAccess,
+		GrantId:   grantId,
+		ClientId:  clientId,
+		UserId:    userId,
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: time.Now().Add(s.OAuth.AccessTokenTTL),
+	}
+
+	if result := tx.Create(&access); result.Error != nil {
+		return nil, result.Error
+	}
+
+	res := &pb.OAuthTokenResponse{
+		Status:      http.StatusOK,
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.OAuth.AccessTokenTTL.Seconds()),
+		Scope:       access.Scopes,
+	}
+
+	if !withRefresh {
+		return res, nil
+	}
+
+	secret, err = utils.RandomToken(32)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res.RefreshToken = oauthRefreshTokenPrefix + secret
+
+	refresh := access
+	refresh.Id = 0
+	refresh.TokenHash = utils.HashToken(res.RefreshToken)
+	refresh.Kind = models.OAuthTokenRefresh
+	refresh.ExpiresAt = time.Now().Add(s.OAuth.RefreshTokenTTL)
+
+	if result := tx.Create(&refresh); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return res, nil
+}
+
+// authenticateClient checks the client credentials. Public clients have no
+// secret and must not send one.
+func (s *Server) authenticateClient(clientId string, secret string) (*models.OAuthClient, bool) {
+	if clientId == "" {
+		return nil, false
+	}
+
+	var client models.OAuthClient
+
+	if result := s.H.DB.Where("client_id = ?", clientId).First(&client); result.Error != nil {
+		return nil, false
+	}
+
+	if !client.Confidential {
+		return &client, secret == ""
+	}
+
+	if secret == "" || subtle.ConstantTimeCompare([]byte(utils.HashToken(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, false
+	}
+
+	return &client, true
+}
+
+func (s *Server) revokeOAuthGrant(grantId string) {
+	if grantId == "" {
+		return
+	}
+
+	var tokens []models.OAuthToken
+
+	if result := s.H.DB.Where("grant_id = ? AND kind = ? AND revoked_at IS NULL", grantId, models.OAuthTokenAccess).Find(&tokens); result.Error != nil {
+		log.Println("Failed to revoke OAuth grant:", result.Error)
+		return
+	}
+
+	result := s.H.DB.Model(&models.OAuthToken{}).
+		Where("grant_id = ? AND revoked_at IS NULL", grantId).
+		Update("revoked_at", time.Now())
+
+	if result.Error != nil {
+		log.Println("Failed to revoke OAuth grant:", result.Error)
+		return
+	}
+
+	for _, token := range tokens {
+		s.publishOAuthRevocation(token)
+	}
+}
+
+// publishOAuthRevocation tells verifiers to drop a cached access token. The
+// token is named by its hash, which is also how the gateway keys its cache.
+func (s *Server) publishOAuthRevocation(token models.OAuthToken) {
+	s.revocations.publish(&pb.RevocationNotice{Jti: token.TokenHash, ExpiresAt: token.ExpiresAt.Unix()})
+}
+
+// grantScopes picks the scopes for a new grant: the requested ones, or all of
+// the client's when none are asked for, limited to what the user holds.
+func grantScopes(client models.OAuthClient, scope string, user models.User) ([]string, bool) {
+	allowed := client.ScopeList()
+	requested := strings.Fields(scope)
+
+	if len(requested) == 0 {
+		requested = allowed
+	}
+
+	held := models.Permissions(user.RoleNames())
+	var granted []string
+
+	for _, scope := range requested {
+		if !containsString(allowed, scope) {
+			return nil, false
+		}
+
+		if containsString(held, scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	return granted, len(granted) > 0
+}
+
+func verifyCodeChallenge(challenge string, verifier string) bool {
+	if !codeVerifierPattern.MatchString(verifier) {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) == 1
+}
+
+// checkRedirectURI requires an absolute URI without a fragment (RFC 6749
+// 3.1.2). Plain http is only allowed for loopback addresses.
+func checkRedirectURI(uri string) error {
+	u, err := url.Parse(uri)
+
+	if err != nil || u.Host == "" || u.Fragment != "" || strings.ContainsAny(uri, " ") {
+		return errors.New("Invalid redirect URI " + strconv.Quote(uri))
+	}
+
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if host := u.Hostname(); host == "localhost" || host == "127.0.0.1" || host == "::1" {
+			return nil
+		}
+	}
+
+	return errors.New("Redirect URI must use https: " + strconv.Quote(uri))
+}
+
+func withQuery(uri string, params map[string]string) string {
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return uri
+	}
+
+	query := u.Query()
+
+	for key, value := range params {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+func authorizeError(redirectURI string, state string, code string, description string) *pb.OAuthAuthorizeResponse {
+	return &pb.OAuthAuthorizeResponse{
+		Status:           http.StatusFound,
+		Error:            code,
+		ErrorDescription: description,
+		RedirectUri: withQuery(redirectURI, map[string]string{
+			"error":             code,
+			"error_description": description,
+			"state":             state,
+		}),
+	}
+}
+
+func tokenError(status int, code string, description string) *pb.OAuthTokenResponse {
+	return &pb.OAuthTokenResponse{
+		Status:           int64(status),
+		Error:            code,
+		ErrorDescription: description,
+	}
+}
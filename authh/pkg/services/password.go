@@ -0,0 +1,49 @@
+package services
+
+import (
+	"log"
+
+	"authh/pkg/models"
+	"authh/pkg/utils"
+)
+
+func (s *Server) hasher() *utils.PasswordHasher {
+	if s.Hasher == nil {
+		return utils.DefaultPasswordHasher
+	}
+
+	return s.Hasher
+}
+
+// checkPassword verifies the password of the user and, when it matches,
+// upgrades a hash made with outdated settings while the plain password is at
+// hand.
+func (s *Server) checkPassword(user *models.User, password string) bool {
+	match, err := s.hasher().Verify(password, user.Password)
+
+	if err != nil {
+		log.Println("Failed to verify password:", err)
+	}
+
+	if !match || !s.hasher().NeedsRehash(user.Password) {
+		return match
+	}
+
+	hash, err := s.hasher().Hash(password)
+
+	if err != nil {
+		log.Println("Failed to rehash password:", err)
+		return match
+	}
+
+	// Conditional so that a password changed meanwhile isn't overwritten.
+	result := s.H.DB.Model(&models.User{}).
+		Where("id = ? AND password = ?", user.Id, user.Password).
+		Update("password", hash)
+
+	if result.Error != nil {
+		log.Println("Failed to rehash password:", result.Error)
+	}
+
+	return match
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"authh/pkg/models"
+	"authh/pkg/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm/clause"
+)
+
+// revocationHub fans revocation notices out to every WatchRevocations stream
+// of this instance. Notices published by other instances arrive through
+// RelayRevocations.
+type revocationHub struct {
+	mu          sync.Mutex
+	subscribers map[chan *pb.RevocationNotice]struct{}
+}
+
+func (h *revocationHub) subscribe() chan *pb.RevocationNotice {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subscribers == nil {
+		h.subscribers = make(map[chan *pb.RevocationNotice]struct{})
+	}
+
+	ch := make(chan *pb.RevocationNotice, 64)
+	h.subscribers[ch] = struct{}{}
+
+	return ch
+}
+
+func (h *revocationHub) unsubscribe(ch chan *pb.RevocationNotice) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[ch]; ok {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish never blocks. A subscriber that can't keep up is dropped, which
+// ends its stream so that it reconnects and starts over from a snapshot.
+func (h *revocationHub) publish(notice *pb.RevocationNotice) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- notice:
+		default:
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publishUserChange records that a user was disabled, deleted or given other
+// roles and tells the watchers. The record outlives every access token issued
+// before the change, so a watcher that connects later still hears of it.
+func (s *Server) publishUserChange(userId int64) {
+	now := time.Now()
+	ttl := s.Jwt.Expiration
+
+	if s.OAuth.AccessTokenTTL > ttl {
+		ttl = s.OAuth.AccessTokenTTL
+	}
+
+	change := models.UserChange{
+		UserId:    userId,
+		ChangedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	if result := s.H.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&change); result.Error != nil {
+		log.Println("Failed to record user change:", result.Error)
+	}
+
+	s.revocations.publish(&pb.RevocationNotice{UserId: userId, ChangedAt: now.Unix()})
+}
+
+// RelayRevocations periodically reads the revocations and user changes that
+// any instance recorded and publishes them to this instance's watchers, so
+// that authh can run as several replicas behind one database. Each poll looks
+// back one more interval to catch late commits and small clock skew between
+// instances; verifiers take repeated notices in their stride.
+func (s *Server) RelayRevocations(interval time.Duration) {
+	since := time.Now()
+
+	for range time.Tick(interval) {
+		now := time.Now()
+		s.relayRevocationsSince(since.Add(-interval), now)
+		since = now
+	}
+}
+
+func (s *Server) relayRevocationsSince(since time.Time, now time.Time) {
+	var revoked []models.RevokedToken
+
+	if result := s.H.DB.Where("revoked_at > ? AND expires_at > ?", since, now).Find(&revoked); result.Error != nil {
+		log.Println("Failed to poll revoked tokens:", result.Error)
+	}
+
+	for _, token := range revoked {
+		s.revocations.publish(&pb.RevocationNotice{Jti: token.Jti, ExpiresAt: token.ExpiresAt.Unix()})
+	}
+
+	var changes []models.UserChange
+
+	if result := s.H.DB.Where("changed_at > ?", since).Find(&changes); result.Error != nil {
+		log.Println("Failed to poll user changes:", result.Error)
+	}
+
+	for _, change := range changes {
+		s.revocations.publish(&pb.RevocationNotice{UserId: change.UserId, ChangedAt: change.ChangedAt.Unix()})
+	}
+
+	var tokens []models.OAuthToken
+
+	if result := s.H.DB.Where("revoked_at > ? AND expires_at > ?", since, now).Find(&tokens); result.Error != nil {
+		log.Println("Failed to poll OAuth tokens:", result.Error)
+	}
+
+	for _, token := range tokens {
+		s.publishOAuthRevocation(token)
+	}
+
+	var keys []models.APIKey
+
+	if result := s.H.DB.Where("revoked_at > ?", since).Find(&keys); result.Error != nil {
+		log.Println("Failed to poll API keys:", result.Error)
+	}
+
+	for _, key := range keys {
+		s.revocations.publish(&pb.RevocationNotice{Jti: apiKeyJti(key.Id), ExpiresAt: now.Unix()})
+	}
+}
+
+// WatchRevocations streams every access token revoked and every user changed
+// within the lifetime of an access token, a synced marker, and then new
+// revocations and user changes as they happen. Verifiers that check tokens
+// locally use it to know when they can't trust a token.
+func (s *Server) WatchRevocations(req *pb.WatchRevocationsRequest, stream pb.AuthService_WatchRevocationsServer) error {
+	// Subscribe before reading the snapshot so nothing falls in between.
+	ch := s.revocations.subscribe()
+	defer s.revocations.unsubscribe(ch)
+
+	var revoked []models.RevokedToken
+
+	if result := s.H.DB.Where("expires_at > ?", time.Now()).Find(&revoked); result.Error != nil {
+		return status.Error(codes.Internal, result.Error.Error())
+	}
+
+	for _, token := range revoked {
+		if err := stream.Send(&pb.RevocationNotice{Jti: token.Jti, ExpiresAt: token.ExpiresAt.Unix()}); err != nil {
+			return err
+		}
+	}
+
+	var changes []models.UserChange
+
+	if result := s.H.DB.Where("expires_at > ?", time.Now()).Find(&changes); result.Error != nil {
+		return status.Error(codes.Internal, result.Error.Error())
+	}
+
+	for _, change := range changes {
+		if err := stream.Send(&pb.RevocationNotice{UserId: change.UserId, ChangedAt: change.ChangedAt.Unix()}); err != nil {
+			return err
+		}
+	}
+
+	if err := stream.Send(&pb.RevocationNotice{Synced: true}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case notice, ok := <-ch:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "Subscriber fell behind")
+			}
+
+			if err := stream.Send(notice); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"authh/pkg/models"
+	"authh/pkg/pb"
+)
+
+// AssignRoles replaces the roles of a user. Callers are expected to have
+// checked that the requester is an admin.
+func (s *Server) AssignRoles(ctx context.Context, req *pb.AssignRolesRequest) (*pb.AssignRolesResponse, error) {
+	for _, name := range req.Roles {
+		if !models.IsRole(name) {
+			return &pb.AssignRolesResponse{
+				Status: http.StatusBadRequest,
+				Error:  fmt.Sprintf("Unknown role %q", name),
+			}, nil
+		}
+	}
+
+	var user models.User
+
+	if result := s.H.DB.First(&user, req.UserId); result.Error != nil {
+		return &pb.AssignRolesResponse{
+			Status: http.StatusNotFound,
+			Error:  "User not found",
+		}, nil
+	}
+
+	roles, err := s.findRoles(req.Roles)
+
+	if err == nil {
+		err = s.H.DB.Model(&user).Association("Roles").Replace(roles)
+	}
+
+	if err != nil {
+		return &pb.AssignRolesResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	// Tokens issued before now carry the old roles.
+	s.publishUserChange(user.Id)
+
+	return &pb.AssignRolesResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+func (s *Server) findRoles(names []string) ([]models.Role, error) {
+	var roles []models.Role
+
+	if len(names) == 0 {
+		return roles, nil
+	}
+
+	if result := s.H.DB.Where("name IN ?", names).Find(&roles); result.Error != nil {
+		return nil, result.Error
+	}
+
+	unique := make(map[string]bool)
+
+	for _, name := range names {
+		unique[name] = true
+	}
+
+	if len(roles) != len(unique) {
+		return nil, fmt.Errorf("roles %v are not seeded", names)
+	}
+
+	return roles, nil
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"authh/pkg/models"
+	"authh/pkg/pb"
+	"google.golang.org/grpc/metadata"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ClientIPMetadataKey carries the address of the end user, set by the gateway.
+const ClientIPMetadataKey = "x-client-ip"
+
+// UserAgentMetadataKey carries the User-Agent of the end user. grpc-go
+// overwrites the standard user-agent key with its own.
+const UserAgentMetadataKey = "x-client-user-agent"
+
+// UserIdMetadataKey carries the id of the user the gateway authenticated.
+const UserIdMetadataKey = "x-user-id"
+
+// LoginLimits configures brute-force protection. Each failed login makes the
+// account and the client IP wait Backoff, doubling with every further
+// failure, and reaching the maximum locks them out for Lockout. A zero
+// maximum turns tracking off.
+type LoginLimits struct {
+	MaxFailures   int
+	MaxIPFailures int
+	Backoff       time.Duration
+	Lockout       time.Duration
+}
+
+// ResetLimits caps how many password resets may be requested for one address
+// and from one client IP within Window. A zero maximum turns the limit off.
+type ResetLimits struct {
+	MaxRequests   int
+	MaxIPRequests int
+	Window        time.Duration
+}
+
+func clientIP(ctx context.Context) string {
+	return incomingMetadata(ctx, ClientIPMetadataKey)
+}
+
+func incomingMetadata(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+func accountKey(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func resetAccountKey(email string) string {
+	return "reset-email:" + strings.ToLower(email)
+}
+
+func resetIPKey(ip string) string {
+	return "reset-ip:" + ip
+}
+
+// verifyPassword checks the password of a signed-in user under the same
+// throttle as Login, so a stolen session cannot be used to guess it. A
+// positive delay means the attempt was refused without checking; a wrong
+// password counts as a failed login.
+func (s *Server) verifyPassword(ctx context.Context, user *models.User, password string) (time.Duration, bool) {
+	ip := clientIP(ctx)
+
+	if delay := s.loginDelay(user.Email, ip); delay > 0 {
+		return delay, false
+	}
+
+	if !s.checkPassword(user, password) {
+		s.recordLoginFailure(user.Email, ip)
+		return 0, false
+	}
+
+	return 0, true
+}
+
+// loginDelay returns how long the account or IP must still wait before the
+// next attempt.
+func (s *Server) loginDelay(email string, ip string) time.Duration {
+	if s.Limits.MaxFailures == 0 && s.Limits.MaxIPFailures == 0 {
+		return 0
+	}
+
+	var throttles []models.LoginThrottle
+
+	if result := s.H.DB.Where("key IN ?", []string{accountKey(email), ipKey(ip)}).Find(&throttles); result.Error != nil {
+		log.Println("Failed to read login throttles:", result.Error)
+		return 0
+	}
+
+	var delay time.Duration
+
+	for _, t := range throttles {
+		if t.LockedUntil != nil {
+			if wait := time.Until(*t.LockedUntil); wait > delay {
+				delay = wait
+			}
+		}
+	}
+
+	return delay
+}
+
+func (s *Server) recordLoginFailure(email string, ip string) {
+	if s.Limits.MaxFailures > 0 {
+		if err := s.fail(accountKey(email), s.Limits.MaxFailures, email, ip); err != nil {
+			log.Println("Failed to record login failure:", err)
+		}
+	}
+
+	if s.Limits.MaxIPFailures > 0 && ip != "" {
+		if err := s.fail(ipKey(ip), s.Limits.MaxIPFailures, email, ip); err != nil {
+			log.Println("Failed to record login failure:", err)
+		}
+	}
+}
+
+func (s *Server) fail(key string, max int, email string, ip string) error {
+	return s.H.DB.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LoginThrottle{Key: key}); result.Error != nil {
+			return result.Error
+		}
+
+		var t models.LoginThrottle
+
+		if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, "key = ?", key); result.Error != nil {
+			return result.Error
+		}
+
+		now := time.Now()
+
+		// Failures older than a lockout no longer count.
+		if now.Sub(t.LastFailureAt) > s.Limits.Lockout {
+			t.Failures = 0
+		}
+
+		t.Failures++
+		t.LastFailureAt = now
+
+		delay := s.Limits.Lockout
+
+		if t.Failures < max {
+			delay = s.Limits.Backoff << (t.Failures - 1)
+
+			if delay <= 0 || delay > s.Limits.Lockout {
+				delay = s.Limits.Lockout
+			}
+		}
+
+		until := now.Add(delay)
+		t.LockedUntil = &until
+
+		if t.Failures >= max {
+			event := models.AuthEvent{
+				Type:   models.AuthEventLockout,
+				Email:  email,
+				IP:     ip,
+				Result: models.AuthEventFailure,
+				Status: http.StatusTooManyRequests,
+				Detail: fmt.Sprintf("%s locked until %s after %d failed logins", key, until.Format(time.RFC3339), t.Failures),
+			}
+
+			if result := tx.Create(&event); result.Error != nil {
+				return result.Error
+			}
+
+			log.Printf("Locked out %s after %d failed logins until %s", key, t.Failures, until.Format(time.RFC3339))
+		}
+
+		return tx.Save(&t).Error
+	})
+}
+
+// resetDelay counts a password reset request for the address and IP and
+// returns how long the caller must wait when either went over its limit.
+func (s *Server) resetDelay(email string, ip string) time.Duration {
+	var delay time.Duration
+
+	if s.ResetLimits.MaxRequests > 0 {
+		delay = s.countRequest(resetAccountKey(email), s.ResetLimits.MaxRequests)
+	}
+
+	if s.ResetLimits.MaxIPRequests > 0 && ip != "" {
+		if wait := s.countRequest(resetIPKey(ip), s.ResetLimits.MaxIPRequests); wait > delay {
+			delay = wait
+		}
+	}
+
+	return delay
+}
+
+// countRequest adds a request to the window of the key, which starts with
+// the first request and ends at LockedUntil, and returns the rest of the
+// window once more than max requests were made in it.
+func (s *Server) countRequest(key string, max int) time.Duration {
+	var delay time.Duration
+
+	err := s.H.DB.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LoginThrottle{Key: key}); result.Error != nil {
+			return result.Error
+		}
+
+		var t models.LoginThrottle
+
+		if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, "key = ?", key); result.Error != nil {
+			return result.Error
+		}
+
+		now := time.Now()
+
+		if t.LockedUntil == nil || !now.Before(*t.LockedUntil) {
+			end := now.Add(s.ResetLimits.Window)
+			t.Failures = 0
+			t.LockedUntil = &end
+		}
+
+		t.Failures++
+		t.LastFailureAt = now
+
+		if t.Failures > max {
+			delay = t.LockedUntil.Sub(now)
+		}
+
+		return tx.Save(&t).Error
+	})
+
+	if err != nil {
+		log.Println("Failed to count password reset request:", err)
+	}
+
+	return delay
+}
+
+func (s *Server) resetLoginFailures(email string) {
+	if result := s.H.DB.Delete(&models.LoginThrottle{}, "key = ?", accountKey(email)); result.Error != nil {
+		log.Println("Failed to reset login failures:", result.Error)
+	}
+}
+
+// Unlock lifts a lockout early. Callers are expected to have checked that
+// the requester is an admin.
+func (s *Server) Unlock(ctx context.Context, req *pb.UnlockRequest) (*pb.UnlockResponse, error) {
+	var keys []string
+
+	if req.UserId != 0 {
+		var user models.User
+
+		if result := s.H.DB.First(&user, req.UserId); result.Error != nil {
+			return &pb.UnlockResponse{
+				Status: http.StatusNotFound,
+				Error:  "User not found",
+			}, nil
+		}
+
+		keys = append(keys, accountKey(user.Email))
+	}
+
+	if req.Ip != "" {
+		keys = append(keys, ipKey(req.Ip))
+	}
+
+	if len(keys) == 0 {
+		return &pb.UnlockResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Either userId or ip is required",
+		}, nil
+	}
+
+	if result := s.H.DB.Delete(&models.LoginThrottle{}, "key IN ?", keys); result.Error != nil {
+		return &pb.UnlockResponse{
+			Status: http.StatusInternalServerError,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	return &pb.UnlockResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+func tooManyAttempts(delay time.Duration) string {
+	return fmt.Sprintf("Too many failed login attempts, retry in %s", delay.Round(time.Second))
+}
+
+func tooManyResets(delay time.Duration) string {
+	return fmt.Sprintf("Too many password reset requests, retry in %s", delay.Round(time.Second))
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"authh/pkg/models"
+	"authh/pkg/pb"
+	"authh/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errRefreshTokenUsed = errors.New("Refresh token has already been used")
+
+func (s *Server) Refresh(ctx context.Context, req *pb.RefreshRequest) (*pb.RefreshResponse, error) {
+	var stored models.RefreshToken
+
+	if result := s.H.DB.Where(&models.RefreshToken{TokenHash: utils.HashToken(req.RefreshToken)}).First(&stored); result.Error != nil {
+		return &pb.RefreshResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Invalid refresh token",
+		}, nil
+	}
+
+	if stored.RevokedAt != nil {
+		// A rotated token was presented again, so it has probably leaked:
+		// end the whole session rather than just this token.
+		s.revokeFamily(stored.FamilyId)
+
+		return &pb.RefreshResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Refresh token has been revoked",
+		}, nil
+	}
+
+	auditUser(ctx, stored.UserId)
+
+	if stored.ExpiresAt.Before(time.Now()) {
+		return &pb.RefreshResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Refresh token is expired",
+		}, nil
+	}
+
+	var user models.User
+
+	if result := s.H.DB.Preload("Roles").First(&user, stored.UserId); result.Error != nil {
+		return &pb.RefreshResponse{
+			Status: http.StatusNotFound,
+			Error:  "User not found",
+		}, nil
+	}
+
+	if user.Disabled {
+		return &pb.RefreshResponse{
+			Status: http.StatusForbidden,
+			Error:  accountDisabled,
+		}, nil
+	}
+
+	// Revoke the presented token only if nobody else rotated it first.
+	result := s.H.DB.Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", stored.Id).
+		Update("revoked_at", time.Now())
+
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = errRefreshTokenUsed
+	}
+
+	if result.Error != nil {
+		return &pb.RefreshResponse{
+			Status: http.StatusUnauthorized,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	token, refreshToken, err := s.issueTokens(user, stored.FamilyId)
+
+	if err != nil {
+		return &pb.RefreshResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	return &pb.RefreshResponse{
+		Status:       http.StatusOK,
+		Token:        token,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (s *Server) Logout(ctx context.Context, req *pb.LogoutRequest) (*pb.LogoutResponse, error) {
+	if claims, err := s.Jwt.ValidateToken(req.Token); err == nil {
+		auditUser(ctx, claims.Id)
+
+		if err := s.revokeAccessToken(claims.StandardClaims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+			return &pb.LogoutResponse{
+				Status: http.StatusInternalServerError,
+				Error:  err.Error(),
+			}, nil
+		}
+
+		s.revocations.publish(&pb.RevocationNotice{Jti: claims.StandardClaims.Id, ExpiresAt: claims.ExpiresAt})
+	}
+
+	if req.RefreshToken != "" {
+		var stored models.RefreshToken
+
+		if result := s.H.DB.Where(&models.RefreshToken{TokenHash: utils.HashToken(req.RefreshToken)}).First(&stored); result.Error == nil {
+			s.revokeFamily(stored.FamilyId)
+		}
+	}
+
+	return &pb.LogoutResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+// issueTokens signs a new access token for the user together with a refresh
+// token. An empty familyId starts a new session; rotation passes the family of
+// the token being replaced so reuse of an old token can end the session.
+func (s *Server) issueTokens(user models.User, familyId string) (string, string, error) {
+	token, err := s.Jwt.GenerateToken(user)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err := utils.RandomToken(32)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	if familyId == "" {
+		if familyId, err = utils.RandomToken(16); err != nil {
+			return "", "", err
+		}
+	}
+
+	stored := models.RefreshToken{
+		UserId:    user.Id,
+		TokenHash: utils.HashToken(refreshToken),
+		FamilyId:  familyId,
+		ExpiresAt: time.Now().Add(s.RefreshTokenTTL),
+	}
+
+	if result := s.H.DB.Create(&stored); result.Error != nil {
+		return "", "", result.Error
+	}
+
+	return token, refreshToken, nil
+}
+
+func (s *Server) revokeFamily(familyId string) {
+	result := s.H.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyId).
+		Update("revoked_at", time.Now())
+
+	if result.Error != nil {
+		log.Println("Failed to revoke refresh tokens:", result.Error)
+	}
+}
+
+func (s *Server) revokeAccessToken(jti string, expiresAt time.Time) error {
+	revoked := models.RevokedToken{
+		Jti:       jti,
+		ExpiresAt: expiresAt,
+		RevokedAt: time.Now(),
+	}
+
+	return s.H.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error
+}
+
+func (s *Server) isRevoked(jti string) bool {
+	var revoked models.RevokedToken
+
+	err := s.H.DB.Where(&models.RevokedToken{Jti: jti}).First(&revoked).Error
+
+	return !errors.Is(err, gorm.ErrRecordNotFound)
+}
+
+// PurgeExpiredTokens periodically drops revocations, user changes, refresh
+// tokens, mailed tokens, OAuth codes and tokens and login failures that have
+// expired anyway, as well as auth events past their retention.
+func (s *Server) PurgeExpiredTokens(interval time.Duration) {
+	for range time.Tick(interval) {
+		now := time.Now()
+
+		if result := s.H.DB.Where("expires_at < ?", now).Delete(&models.RevokedToken{}); result.Error != nil {
+			log.Println("Failed to purge revoked tokens:", result.Error)
+		}
+
+		if result := s.H.DB.Where("expires_at < ?", now).Delete(&models.UserChange{}); result.Error != nil {
+			log.Println("Failed to purge user changes:", result.Error)
+		}
+
+		if result := s.H.DB.Where("expires_at < ?", now).Delete(&models.RefreshToken{}); result.Error != nil {
+			log.Println("Failed to purge refresh tokens:", result.Error)
+		}
+
+		if result := s.H.DB.Where("expires_at < ?", now).Delete(&models.ActionToken{}); result.Error != nil {
+			log.Println("Failed to purge action tokens:", result.Error)
+		}
+
+		if result := s.H.DB.Where("expires_at < ?", now).Delete(&models.OAuthCode{}); result.Error != nil {
+			log.Println("Failed to purge OAuth codes:", result.Error)
+		}
+
+		if result := s.H.DB.Where("expires_at < ?", now).Delete(&models.OAuthToken{}); result.Error != nil {
+			log.Println("Failed to purge OAuth tokens:", result.Error)
+		}
+
+		// A zero retention keeps the audit log forever.
+		if s.EventRetention > 0 {
+			if result := s.H.DB.Where("created_at < ?", now.Add(-s.EventRetention)).Delete(&models.AuthEvent{}); result.Error != nil {
+				log.Println("Failed to purge auth events:", result.Error)
+			}
+		}
+
+		// Password reset windows end at locked_until, which may be later.
+		if result := s.H.DB.Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", now.Add(-s.Limits.Lockout), now).Delete(&models.LoginThrottle{}); result.Error != nil {
+			log.Println("Failed to purge login throttles:", result.Error)
+		}
+	}
+}
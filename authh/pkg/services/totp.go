@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	"authh/pkg/models"
+	"authh/pkg/pb"
+	"authh/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// challengeTTL is how long a user has to enter the second factor.
+	challengeTTL      = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+var totpCodePattern = regexp.MustCompile(`^[0-9]{6}$`)
+
+// EnrollTOTP starts two-factor enrolment once the current password checks
+// out. The secret only takes effect once ConfirmTOTP proves the
+// authenticator produces matching codes.
+func (s *Server) EnrollTOTP(ctx context.Context, req *pb.EnrollTOTPRequest) (*pb.EnrollTOTPResponse, error) {
+	var user models.User
+
+	if result := s.H.DB.First(&user, req.UserId); result.Error != nil {
+		return &pb.EnrollTOTPResponse{
+			Status: http.StatusNotFound,
+			Error:  "User not found",
+		}, nil
+	}
+
+	if user.TOTPEnabled {
+		return &pb.EnrollTOTPResponse{
+			Status: http.StatusConflict,
+			Error:  "Two-factor login already enabled",
+		}, nil
+	}
+
+	delay, ok := s.verifyPassword(ctx, &user, req.Password)
+
+	if delay > 0 {
+		return &pb.EnrollTOTPResponse{
+			Status: http.StatusTooManyRequests,
+			Error:  tooManyAttempts(delay),
+		}, nil
+	}
+
+	if !ok {
+		return &pb.EnrollTOTPResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Password is wrong",
+		}, nil
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+
+	if err == nil {
+		err = s.H.DB.Model(&user).Update("totp_secret", secret).Error
+	}
+
+	if err != nil {
+		return &pb.EnrollTOTPResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	return &pb.EnrollTOTPResponse{
+		Status: http.StatusOK,
+		Secret: secret,
+		Uri:    utils.TOTPURI(s.TOTPIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables two-factor login and returns recovery codes, which are
+// never shown again.
+func (s *Server) ConfirmTOTP(ctx context.Context, req *pb.ConfirmTOTPRequest) (*pb.ConfirmTOTPResponse, error) {
+	var user models.User
+
+	if result := s.H.DB.First(&user, req.UserId); result.Error != nil {
+		return &pb.ConfirmTOTPResponse{
+			Status: http.StatusNotFound,
+			Error:  "User not found",
+		}, nil
+	}
+
+	if user.TOTPEnabled {
+		return &pb.ConfirmTOTPResponse{
+			Status: http.StatusConflict,
+			Error:  "Two-factor login already enabled",
+		}, nil
+	}
+
+	if user.TOTPSecret == "" {
+		return &pb.ConfirmTOTPResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Two-factor enrolment not started",
+		}, nil
+	}
+
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, req.Code, time.Now(), 0)
+
+	if !ok {
+		return &pb.ConfirmTOTPResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Invalid code",
+		}, nil
+	}
+
+	var codes []string
+
+	err := s.H.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&user).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		})
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result := tx.Where("user_id = ?", user.Id).Delete(&models.RecoveryCode{}); result.Error != nil {
+			return result.Error
+		}
+
+		for i := 0; i < recoveryCodeCount; i++ {
+			code, err := utils.GenerateRecoveryCode()
+
+			if err != nil {
+				return err
+			}
+
+			stored := models.RecoveryCode{
+				UserId:   user.Id,
+				CodeHash: utils.HashToken(utils.NormalizeRecoveryCode(code)),
+			}
+
+			if result := tx.Create(&stored); result.Error != nil {
+				return result.Error
+			}
+
+			codes = append(codes, code)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return &pb.ConfirmTOTPResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	return &pb.ConfirmTOTPResponse{
+		Status:        http.StatusOK,
+		RecoveryCodes: codes,
+	}, nil
+}
+
+func (s *Server) DisableTOTP(ctx context.Context, req *pb.DisableTOTPRequest) (*pb.DisableTOTPResponse, error) {
+	var user models.User
+
+	if result := s.H.DB.First(&user, req.UserId); result.Error != nil {
+		return &pb.DisableTOTPResponse{
+			Status: http.StatusNotFound,
+			Error:  "User not found",
+		}, nil
+	}
+
+	if !user.TOTPEnabled {
+		return &pb.DisableTOTPResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Two-factor login is not enabled",
+		}, nil
+	}
+
+	ok, err := s.checkSecondFactor(&user, req.Code)
+
+	if err == nil && !ok {
+		return &pb.DisableTOTPResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Invalid code",
+		}, nil
+	}
+
+	if err == nil {
+		err = s.H.DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&user).Updates(map[string]interface{}{
+				"totp_enabled":   false,
+				"totp_secret":    "",
+				"totp_last_step": 0,
+			})
+
+			if result.Error != nil {
+				return result.Error
+			}
+
+			return tx.Where("user_id = ?", user.Id).Delete(&models.RecoveryCode{}).Error
+		})
+	}
+
+	if err != nil {
+		return &pb.DisableTOTPResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	return &pb.DisableTOTPResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+// VerifySecondFactor completes a login that Login answered with a challenge.
+// Wrong codes count as failed logins, so the lockout also limits guessing.
+func (s *Server) VerifySecondFactor(ctx context.Context, req *pb.VerifySecondFactorRequest) (*pb.LoginResponse, error) {
+	challenge, err := findActionToken(s.H.DB, models.PurposeLoginChallenge, req.ChallengeToken)
+
+	if err != nil {
+		return &pb.LoginResponse{
+			Status: http.StatusUnauthorized,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	auditUser(ctx, challenge.UserId)
+
+	var user models.User
+
+	if result := s.H.DB.Preload("Roles").First(&user, challenge.UserId); result.Error != nil {
+		return &pb.LoginResponse{
+			Status: http.StatusNotFound,
+			Error:  "User not found",
+		}, nil
+	}
+
+	if user.Disabled {
+		return &pb.LoginResponse{
+			Status: http.StatusForbidden,
+			Error:  accountDisabled,
+		}, nil
+	}
+
+	ip := clientIP(ctx)
+
+	if delay := s.loginDelay(user.Email, ip); delay > 0 {
+		return &pb.LoginResponse{
+			Status: http.StatusTooManyRequests,
+			Error:  tooManyAttempts(delay),
+		}, nil
+	}
+
+	ok, err := s.checkSecondFactor(&user, req.Code)
+
+	if err == nil && !ok {
+		s.recordLoginFailure(user.Email, ip)
+
+		return &pb.LoginResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Invalid code",
+		}, nil
+	}
+
+	if err == nil {
+		_, err = consumeActionToken(s.H.DB, models.PurposeLoginChallenge, req.ChallengeToken)
+	}
+
+	if errors.Is(err, errInvalidActionToken) {
+		return &pb.LoginResponse{
+			Status: http.StatusUnauthorized,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	if err != nil {
+		return &pb.LoginResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	s.resetLoginFailures(user.Email)
+
+	return s.completeLogin(user)
+}
+
+// checkSecondFactor accepts a TOTP code not used before or an unused
+// recovery code, using it up.
+func (s *Server) checkSecondFactor(user *models.User, code string) (bool, error) {
+	if totpCodePattern.MatchString(code) {
+		step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+
+		if !ok {
+			return false, nil
+		}
+
+		// Conditional so that two requests can't both use the same code.
+		result := s.H.DB.Model(&models.User{}).
+			Where("id = ? AND totp_last_step < ?", user.Id, step).
+			Update("totp_last_step", step)
+
+		return result.RowsAffected == 1, result.Error
+	}
+
+	result := s.H.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.Id, utils.HashToken(utils.NormalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+
+	return result.RowsAffected == 1, result.Error
+}
@@ -0,0 +1,380 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"authh/pkg/models"
+	"authh/pkg/pb"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultUserPageSize = 20
+	maxUserPageSize     = 100
+)
+
+type userCursor struct {
+	Id int64 `json:"id"`
+}
+
+func toUserData(user models.User) *pb.UserData {
+	data := &pb.UserData{
+		Id:            user.Id,
+		Email:         user.Email,
+		Name:          user.Name,
+		EmailVerified: user.EmailVerified,
+		Disabled:      user.Disabled,
+		TotpEnabled:   user.TOTPEnabled,
+		Roles:         user.RoleNames(),
+		CreatedAt:     user.CreatedAt.Unix(),
+		UpdatedAt:     user.UpdatedAt.Unix(),
+	}
+
+	if user.LastLoginAt != nil {
+		data.LastLoginAt = user.LastLoginAt.Unix()
+	}
+
+	return data
+}
+
+func (s *Server) findUser(id int64) (*models.User, *pb.UserResponse) {
+	var user models.User
+
+	if result := s.H.DB.Preload("Roles").First(&user, id); result.Error != nil {
+		return nil, &pb.UserResponse{
+			Status: http.StatusNotFound,
+			Error:  "User not found",
+		}
+	}
+
+	return &user, nil
+}
+
+func (s *Server) GetMe(ctx context.Context, req *pb.GetMeRequest) (*pb.UserResponse, error) {
+	user, res := s.findUser(req.UserId)
+
+	if res != nil {
+		return res, nil
+	}
+
+	return &pb.UserResponse{
+		Status: http.StatusOK,
+		User:   toUserData(*user),
+	}, nil
+}
+
+func (s *Server) UpdateMe(ctx context.Context, req *pb.UpdateMeRequest) (*pb.UserResponse, error) {
+	user, res := s.findUser(req.UserId)
+
+	if res != nil {
+		return res, nil
+	}
+
+	if result := s.H.DB.Model(user).Update("name", strings.TrimSpace(req.Name)); result.Error != nil {
+		return &pb.UserResponse{
+			Status: http.StatusInternalServerError,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	return &pb.UserResponse{
+		Status: http.StatusOK,
+		User:   toUserData(*user),
+	}, nil
+}
+
+// ChangePassword ends every session of the user; the access token used for
+// the request stays valid until it expires.
+func (s *Server) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error) {
+	user, res := s.findUser(req.UserId)
+
+	if res != nil {
+		return &pb.ChangePasswordResponse{Status: res.Status, Error: res.Error}, nil
+	}
+
+	delay, ok := s.verifyPassword(ctx, user, req.CurrentPassword)
+
+	if delay > 0 {
+		return &pb.ChangePasswordResponse{
+			Status: http.StatusTooManyRequests,
+			Error:  tooManyAttempts(delay),
+		}, nil
+	}
+
+	if !ok {
+		return &pb.ChangePasswordResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Current password is wrong",
+		}, nil
+	}
+
+	if err := s.Policy.Check(req.NewPassword, user.Email); err != nil {
+		return &pb.ChangePasswordResponse{
+			Status: http.StatusBadRequest,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	hash, err := s.hasher().Hash(req.NewPassword)
+
+	if err == nil {
+		err = s.H.DB.Transaction(func(tx *gorm.DB) error {
+			if result := tx.Model(user).Update("password", hash); result.Error != nil {
+				return result.Error
+			}
+
+			return tx.Model(&models.RefreshToken{}).
+				Where("user_id = ? AND revoked_at IS NULL", user.Id).
+				Update("revoked_at", time.Now()).Error
+		})
+	}
+
+	if err != nil {
+		return &pb.ChangePasswordResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	return &pb.ChangePasswordResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+// ChangeEmail switches the address right away and asks to verify the new one.
+func (s *Server) ChangeEmail(ctx context.Context, req *pb.ChangeEmailRequest) (*pb.UserResponse, error) {
+	user, res := s.findUser(req.UserId)
+
+	if res != nil {
+		return res, nil
+	}
+
+	delay, ok := s.verifyPassword(ctx, user, req.Password)
+
+	if delay > 0 {
+		return &pb.UserResponse{
+			Status: http.StatusTooManyRequests,
+			Error:  tooManyAttempts(delay),
+		}, nil
+	}
+
+	if !ok {
+		return &pb.UserResponse{
+			Status: http.StatusUnauthorized,
+			Error:  "Password is wrong",
+		}, nil
+	}
+
+	if req.Email == "" {
+		return &pb.UserResponse{
+			Status: http.StatusBadRequest,
+			Error:  "E-Mail is required",
+		}, nil
+	}
+
+	var existing models.User
+
+	if result := s.H.DB.Where(&models.User{Email: req.Email}).First(&existing); result.Error == nil {
+		return &pb.UserResponse{
+			Status: http.StatusConflict,
+			Error:  "E-Mail already exists",
+		}, nil
+	}
+
+	result := s.H.DB.Model(user).Updates(map[string]interface{}{
+		"email":          req.Email,
+		"email_verified": false,
+	})
+
+	if result.Error != nil {
+		return &pb.UserResponse{
+			Status: http.StatusInternalServerError,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	if err := s.sendVerification(*user); err != nil {
+		log.Println("Failed to send verification:", err)
+	}
+
+	return &pb.UserResponse{
+		Status: http.StatusOK,
+		User:   toUserData(*user),
+	}, nil
+}
+
+func (s *Server) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	pageSize := int(req.PageSize)
+
+	if pageSize <= 0 {
+		pageSize = defaultUserPageSize
+	}
+
+	if pageSize > maxUserPageSize {
+		pageSize = maxUserPageSize
+	}
+
+	query := s.H.DB.Preload("Roles").Order("id")
+
+	if req.Query != "" {
+		pattern := "%" + escapeLike(req.Query) + "%"
+		query = query.Where("email ILIKE ? OR name ILIKE ?", pattern, pattern)
+	}
+
+	if !req.IncludeDisabled {
+		query = query.Where("disabled = ?", false)
+	}
+
+	if req.PageToken != "" {
+		var cursor userCursor
+
+		raw, err := base64.RawURLEncoding.DecodeString(req.PageToken)
+
+		if err == nil {
+			err = json.Unmarshal(raw, &cursor)
+		}
+
+		if err != nil {
+			return &pb.ListUsersResponse{
+				Status: http.StatusBadRequest,
+				Error:  "Invalid page token",
+			}, nil
+		}
+
+		query = query.Where("id > ?", cursor.Id)
+	}
+
+	var users []models.User
+
+	// One extra row tells whether there is a next page.
+	if result := query.Limit(pageSize + 1).Find(&users); result.Error != nil {
+		return &pb.ListUsersResponse{
+			Status: http.StatusInternalServerError,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	res := &pb.ListUsersResponse{
+		Status: http.StatusOK,
+	}
+
+	if len(users) > pageSize {
+		users = users[:pageSize]
+
+		raw, _ := json.Marshal(userCursor{Id: users[pageSize-1].Id})
+		res.NextPageToken = base64.RawURLEncoding.EncodeToString(raw)
+	}
+
+	for _, user := range users {
+		res.Users = append(res.Users, toUserData(user))
+	}
+
+	return res, nil
+}
+
+// DisableUser blocks logins and ends every session of the user. Callers are
+// expected to have checked that the requester is an admin.
+func (s *Server) DisableUser(ctx context.Context, req *pb.DisableUserRequest) (*pb.UserResponse, error) {
+	return s.setDisabled(req.UserId, true)
+}
+
+func (s *Server) EnableUser(ctx context.Context, req *pb.EnableUserRequest) (*pb.UserResponse, error) {
+	return s.setDisabled(req.UserId, false)
+}
+
+func (s *Server) setDisabled(id int64, disabled bool) (*pb.UserResponse, error) {
+	user, res := s.findUser(id)
+
+	if res != nil {
+		return res, nil
+	}
+
+	err := s.H.DB.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Model(user).Update("disabled", disabled); result.Error != nil {
+			return result.Error
+		}
+
+		if !disabled {
+			return nil
+		}
+
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.Id).
+			Update("revoked_at", time.Now()).Error
+	})
+
+	if err != nil {
+		return &pb.UserResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	// Access tokens already handed out must be checked again.
+	s.publishUserChange(user.Id)
+
+	return &pb.UserResponse{
+		Status: http.StatusOK,
+		User:   toUserData(*user),
+	}, nil
+}
+
+func (s *Server) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb.DeleteUserResponse, error) {
+	user, res := s.findUser(req.UserId)
+
+	if res != nil {
+		return &pb.DeleteUserResponse{Status: res.Status, Error: res.Error}, nil
+	}
+
+	err := s.H.DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.RefreshToken{}, &models.ActionToken{}, &models.RecoveryCode{}, &models.APIKey{}, &models.OAuthCode{}, &models.OAuthToken{}} {
+			if result := tx.Where("user_id = ?", user.Id).Delete(model); result.Error != nil {
+				return result.Error
+			}
+		}
+
+		// Clients the user registered go too, with what other users granted them.
+		owned := tx.Model(&models.OAuthClient{}).Select("client_id").Where("owner_id = ?", user.Id)
+
+		for _, model := range []interface{}{&models.OAuthCode{}, &models.OAuthToken{}} {
+			if result := tx.Where("client_id IN (?)", owned).Delete(model); result.Error != nil {
+				return result.Error
+			}
+		}
+
+		if result := tx.Where("owner_id = ?", user.Id).Delete(&models.OAuthClient{}); result.Error != nil {
+			return result.Error
+		}
+
+		if err := tx.Model(user).Association("Roles").Clear(); err != nil {
+			return err
+		}
+
+		return tx.Delete(user).Error
+	})
+
+	if err != nil {
+		return &pb.DeleteUserResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	s.publishUserChange(user.Id)
+
+	return &pb.DeleteUserResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -1,15 +1,156 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
 
-func HashPassword(password string) string {
-	bytes, _ := bcrypt.GenerateFromPassword([]byte(password), 5)
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
 
-	return string(bytes)
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+var errUnknownHash = errors.New("unknown password hash format")
+
+// PasswordHasher hashes new passwords with the configured algorithm and
+// verifies hashes made by any supported one. Hashes are self-describing:
+// bcrypt's own "$2a$<cost>$..." and the PHC string format for argon2id,
+// "$argon2id$v=19$m=<KiB>,t=<passes>,p=<threads>$<salt>$<hash>".
+type PasswordHasher struct {
+	Algorithm     string
+	BcryptCost    int
+	Argon2Memory  uint32
+	Argon2Time    uint32
+	Argon2Threads uint8
+}
+
+// DefaultPasswordHasher is used when nothing is configured.
+var DefaultPasswordHasher = &PasswordHasher{
+	Algorithm:  AlgorithmBcrypt,
+	BcryptCost: bcrypt.DefaultCost,
+}
+
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	hash    []byte
+}
+
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	switch h.Algorithm {
+	case AlgorithmBcrypt:
+		bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+
+		if err != nil {
+			return "", err
+		}
+
+		return string(bytes), nil
+	case AlgorithmArgon2id:
+		salt := make([]byte, 16)
+
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+
+		hash := argon2.IDKey([]byte(password), salt, h.Argon2Time, h.Argon2Memory, h.Argon2Threads, 32)
+
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, h.Argon2Memory, h.Argon2Time, h.Argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(hash)), nil
+	}
+
+	return "", fmt.Errorf("unknown password hash algorithm %q", h.Algorithm)
+}
+
+func (h *PasswordHasher) Verify(password string, encoded string) (bool, error) {
+	if isBcrypt(encoded) {
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+
+		return err == nil, err
+	}
+
+	params, err := parseArgon2id(encoded)
+
+	if err != nil {
+		return false, err
+	}
+
+	hash := argon2.IDKey([]byte(password), params.salt, params.time, params.memory, params.threads, uint32(len(params.hash)))
+
+	return subtle.ConstantTimeCompare(hash, params.hash) == 1, nil
+}
+
+// NeedsRehash reports whether encoded was made with another algorithm or
+// weaker parameters than currently configured.
+func (h *PasswordHasher) NeedsRehash(encoded string) bool {
+	switch h.Algorithm {
+	case AlgorithmBcrypt:
+		if !isBcrypt(encoded) {
+			return true
+		}
+
+		cost, err := bcrypt.Cost([]byte(encoded))
+
+		return err != nil || cost != h.BcryptCost
+	case AlgorithmArgon2id:
+		params, err := parseArgon2id(encoded)
+
+		return err != nil ||
+			params.memory != h.Argon2Memory ||
+			params.time != h.Argon2Time ||
+			params.threads != h.Argon2Threads
+	}
+
+	return false
 }
 
-func CheckPasswordHash(password string, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func parseArgon2id(encoded string) (*argon2Params, error) {
+	parts := strings.Split(encoded, "$")
+
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return nil, errUnknownHash
+	}
+
+	var version int
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, errUnknownHash
+	}
+
+	params := &argon2Params{}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return nil, errUnknownHash
+	}
+
+	var err error
+
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, errUnknownHash
+	}
+
+	if params.hash, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, errUnknownHash
+	}
 
-	return err == nil
+	return params, nil
 }
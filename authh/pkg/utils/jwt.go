@@ -8,15 +8,17 @@ import (
 )
 
 type JwtWrapper struct {
-	SecretKey       string
-	Issuer          string
-	ExpirationHours int64
+	Keys       *KeySet
+	Issuer     string
+	Expiration time.Duration
 }
 
 type jwtClaims struct {
 	jwt.StandardClaims
-	Id    int64
-	Email string
+	Id          int64
+	Email       string
+	Roles       []string
+	Permissions []string
 }
 
 func GenerateTokenMock(email string) (string, error) {
@@ -24,18 +26,37 @@ func GenerateTokenMock(email string) (string, error) {
 	return "testToken", nil
 }
 func (w *JwtWrapper) GenerateToken(user models.User) (signedToken string, err error) {
+	jti, err := RandomToken(16)
+
+	if err != nil {
+		return "", err
+	}
+
+	roles := user.RoleNames()
+
 	claims := &jwtClaims{
-		Id:    user.Id,
-		Email: user.Email,
+		Id:          user.Id,
+		Email:       user.Email,
+		Roles:       roles,
+		Permissions: models.Permissions(roles),
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(w.ExpirationHours)).Unix(),
+			Id:        jti,
+			IssuedAt:  time.Now().Unix(),
+			ExpiresAt: time.Now().Local().Add(w.Expiration).Unix(),
 			Issuer:    w.Issuer,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	key, err := w.Keys.signingKey()
 
-	signedToken, err = token.SignedString([]byte(w.SecretKey))
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.Kid
+
+	signedToken, err = token.SignedString(key.Private)
 
 	if err != nil {
 		return "", err
@@ -48,9 +69,7 @@ func (w *JwtWrapper) ValidateToken(signedToken string) (claims *jwtClaims, err e
 	token, err := jwt.ParseWithClaims(
 		signedToken,
 		&jwtClaims{},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(w.SecretKey), nil
-		},
+		w.Keys.verificationKey,
 	)
 
 	if err != nil {
@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// SigningKey is a private key used to sign tokens, identified by its kid.
+type SigningKey struct {
+	Kid     string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+}
+
+// KeySet holds every key that tokens may have been signed with. Only the
+// active key signs new tokens; the others stay around so that tokens issued
+// before a rotation keep validating until they expire.
+type KeySet struct {
+	Active string
+	Keys   map[string]*SigningKey
+}
+
+func NewKeySet(active string, keys ...*SigningKey) *KeySet {
+	set := &KeySet{Active: active, Keys: make(map[string]*SigningKey)}
+
+	for _, key := range keys {
+		set.Keys[key.Kid] = key
+	}
+
+	return set
+}
+
+// GenerateKey creates a new Ed25519 signing key.
+func GenerateKey(kid string) (*SigningKey, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &SigningKey{Kid: kid, Method: jwt.SigningMethodEdDSA, Private: private}, nil
+}
+
+// LoadKeySet reads every <kid>.pem private key in dir. Rotating means adding
+// a new key file and pointing active at it; when active is empty the key
+// with the greatest kid signs. An empty dir gets a freshly generated key so
+// that a development setup works out of the box.
+func LoadKeySet(dir string, active string) (*KeySet, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		key, err := GenerateKey(time.Now().UTC().Format("20060102150405"))
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := writeKey(dir, key); err != nil {
+			return nil, err
+		}
+
+		return NewKeySet(key.Kid, key), nil
+	}
+
+	set := NewKeySet(active)
+	var kids []string
+
+	for _, file := range files {
+		key, err := readKey(file)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		set.Keys[key.Kid] = key
+		kids = append(kids, key.Kid)
+	}
+
+	if set.Active == "" {
+		sort.Strings(kids)
+		set.Active = kids[len(kids)-1]
+	}
+
+	if _, ok := set.Keys[set.Active]; !ok {
+		return nil, fmt.Errorf("active key %q not found in %s", set.Active, dir)
+	}
+
+	return set, nil
+}
+
+func (s *KeySet) signingKey() (*SigningKey, error) {
+	if s == nil {
+		return nil, errors.New("No signing keys configured")
+	}
+
+	key, ok := s.Keys[s.Active]
+
+	if !ok {
+		return nil, fmt.Errorf("Signing key %q not found", s.Active)
+	}
+
+	return key, nil
+}
+
+// verificationKey returns the public key for a token, refusing tokens whose
+// algorithm doesn't match the key they name.
+func (s *KeySet) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	if s == nil {
+		return nil, errors.New("No signing keys configured")
+	}
+
+	key, ok := s.Keys[kid]
+
+	if !ok {
+		return nil, fmt.Errorf("Unknown key %q", kid)
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("Unexpected signing method %s", token.Method.Alg())
+	}
+
+	return key.Private.Public(), nil
+}
+
+func readKey(file string) (*SigningKey, error) {
+	data, err := os.ReadFile(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+
+	var parsed interface{}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{Kid: kid, Method: jwt.SigningMethodRS256, Private: private}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{Kid: kid, Method: jwt.SigningMethodEdDSA, Private: private}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %T", parsed)
+}
+
+func writeKey(dir string, key *SigningKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+
+	if err != nil {
+		return err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	return os.WriteFile(filepath.Join(dir, key.Kid+".pem"), data, 0600)
+}
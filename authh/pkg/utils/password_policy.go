@@ -0,0 +1,96 @@
+package utils
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// maxPasswordLength is where bcrypt stops looking at the input.
+const maxPasswordLength = 72
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = func() map[string]bool {
+	set := make(map[string]bool)
+
+	for _, line := range strings.Split(commonPasswordList, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			set[strings.ToLower(line)] = true
+		}
+	}
+
+	return set
+}()
+
+// PasswordPolicy describes what passwords Register and password changes
+// accept. MinClasses counts lower case letters, upper case letters, digits
+// and symbols.
+type PasswordPolicy struct {
+	MinLength  int
+	MinClasses int
+}
+
+// PolicyError lists every rule a password broke.
+type PolicyError struct {
+	Problems []string
+}
+
+func (e *PolicyError) Error() string {
+	return "Password " + strings.Join(e.Problems, ", ")
+}
+
+func (p PasswordPolicy) Check(password string, email string) error {
+	var problems []string
+
+	if password == "" {
+		return &PolicyError{Problems: []string{"is required"}}
+	}
+
+	if len([]rune(password)) < p.MinLength {
+		problems = append(problems, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+
+	if len(password) > maxPasswordLength {
+		problems = append(problems, fmt.Sprintf("must be at most %d bytes long", maxPasswordLength))
+	}
+
+	if classes := characterClasses(password); classes < p.MinClasses {
+		problems = append(problems, fmt.Sprintf("must mix at least %d of lower case letters, upper case letters, digits and symbols", p.MinClasses))
+	}
+
+	if commonPasswords[strings.ToLower(password)] {
+		problems = append(problems, "is too common")
+	}
+
+	if strings.EqualFold(password, email) {
+		problems = append(problems, "must not be your e-mail address")
+	}
+
+	if len(problems) > 0 {
+		return &PolicyError{Problems: problems}
+	}
+
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol int
+
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+
+	return lower + upper + digit + symbol
+}
@@ -0,0 +1,254 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/anypb"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// MetadataKey is the gRPC metadata entry the gateway forwards the
+	// Idempotency-Key header in.
+	MetadataKey = "idempotency-key"
+	// UserMetadataKey is the gRPC metadata entry the gateway forwards the id
+	// of the authenticated user in.
+	UserMetadataKey = "x-user-id"
+)
+
+// scope identifies a stored response. Keys are chosen by clients, so the
+// same key sent by another user or to another method is a different request.
+type scope struct {
+	method string
+	userId int64
+	key    string
+}
+
+func (s scope) where(db *gorm.DB) *gorm.DB {
+	return db.Where("method = ? AND user_id = ? AND key = ?", s.method, s.userId, s.key)
+}
+
+// Forward returns ctx carrying the caller's user and idempotency key as
+// outgoing metadata, for a call the handler makes to another service. The key
+// is derived from the caller's and the given parts, so every downstream call
+// made for one request has a key of its own that stays the same on retries.
+func Forward(ctx context.Context, parts ...interface{}) context.Context {
+	if userId := metadataValue(ctx, UserMetadataKey); userId != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, UserMetadataKey, userId)
+	}
+
+	key := metadataValue(ctx, MetadataKey)
+
+	if key == "" {
+		return ctx
+	}
+
+	for _, part := range parts {
+		key += fmt.Sprintf("/%v", part)
+	}
+
+	return metadata.AppendToOutgoingContext(ctx, MetadataKey, key)
+}
+
+// UnaryServerInterceptor makes the given methods idempotent for callers that
+// send an idempotency key: the first response for a key is stored and
+// replayed for every retry within the retention window. Reusing a key for a
+// different request is rejected.
+func UnaryServerInterceptor(db *gorm.DB, retention time.Duration, methods ...string) grpc.UnaryServerInterceptor {
+	guarded := make(map[string]bool, len(methods))
+
+	for _, method := range methods {
+		guarded[method] = true
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		key := metadataValue(ctx, MetadataKey)
+
+		if key == "" || !guarded[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		userId, _ := strconv.ParseInt(metadataValue(ctx, UserMetadataKey), 10, 64)
+		scope := scope{method: info.FullMethod, userId: userId, key: key}
+
+		hash, err := requestHash(info.FullMethod, req)
+
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		db := db.WithContext(ctx)
+
+		if result := scope.where(db).Where("created_at < ?", time.Now().Add(-retention)).Delete(&Record{}); result.Error != nil {
+			return nil, status.Error(codes.Internal, result.Error.Error())
+		}
+
+		record := Record{
+			Method:      scope.method,
+			UserId:      scope.userId,
+			Key:         scope.key,
+			RequestHash: hash,
+			CreatedAt:   time.Now(),
+		}
+
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+
+		if result.Error != nil {
+			return nil, status.Error(codes.Internal, result.Error.Error())
+		}
+
+		if result.RowsAffected == 0 {
+			return replay(db, scope, hash)
+		}
+
+		res, err := handler(ctx, req)
+
+		// Failures are not remembered so that the client can retry them.
+		if err != nil || isServerError(res) {
+			scope.where(db).Delete(&Record{})
+			return res, err
+		}
+
+		stored, err := anypb.New(res.(proto.Message))
+
+		if err == nil {
+			record.Response, err = proto.Marshal(stored)
+		}
+
+		if err == nil {
+			err = scope.where(db.Model(&Record{})).Updates(map[string]interface{}{"response": record.Response, "completed": true}).Error
+		}
+
+		if err != nil {
+			log.Println("Failed to store idempotent response:", err)
+		}
+
+		return res, nil
+	}
+}
+
+// PurgeExpired periodically removes records that are past the retention window.
+func PurgeExpired(db *gorm.DB, retention time.Duration) {
+	for range time.Tick(time.Hour) {
+		if result := db.Where("created_at < ?", time.Now().Add(-retention)).Delete(&Record{}); result.Error != nil {
+			log.Println("Failed to purge idempotency records:", result.Error)
+		}
+	}
+}
+
+func replay(db *gorm.DB, scope scope, hash string) (interface{}, error) {
+	var record Record
+
+	if result := scope.where(db).First(&record); result.Error != nil {
+		return nil, status.Error(codes.Internal, result.Error.Error())
+	}
+
+	if record.RequestHash != hash {
+		return errorResponse(scope.method, http.StatusUnprocessableEntity, "Idempotency key was already used for a different request")
+	}
+
+	if !record.Completed {
+		return errorResponse(scope.method, http.StatusConflict, "A request with this idempotency key is still in progress")
+	}
+
+	var stored anypb.Any
+
+	if err := proto.Unmarshal(record.Response, &stored); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	res, err := stored.UnmarshalNew()
+
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return res, nil
+}
+
+func metadataValue(ctx context.Context, name string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+
+	if !ok {
+		return ""
+	}
+
+	values := md.Get(name)
+
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+func requestHash(method string, req interface{}) (string, error) {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(req.(proto.Message))
+
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(append([]byte(method+"\n"), b...))
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func isServerError(res interface{}) bool {
+	withStatus, ok := res.(interface{ GetStatus() int64 })
+
+	return ok && withStatus.GetStatus() >= http.StatusInternalServerError
+}
+
+// errorResponse builds the method's regular response message carrying the
+// given status and error, the same way the handlers report failures.
+func errorResponse(method string, code int64, message string) (interface{}, error) {
+	name := protoreflect.FullName(strings.ReplaceAll(strings.TrimPrefix(method, "/"), "/", "."))
+
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, message)
+	}
+
+	methodDesc, ok := desc.(protoreflect.MethodDescriptor)
+
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, message)
+	}
+
+	msgType, err := protoregistry.GlobalTypes.FindMessageByName(methodDesc.Output().FullName())
+
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, message)
+	}
+
+	res := msgType.New()
+	fields := res.Descriptor().Fields()
+
+	if field := fields.ByName("status"); field != nil {
+		res.Set(field, protoreflect.ValueOfInt64(code))
+	}
+
+	if field := fields.ByName("error"); field != nil {
+		res.Set(field, protoreflect.ValueOfString(message))
+	}
+
+	return res.Interface(), nil
+}
@@ -0,0 +1,144 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	pb "common/idempotency/internal/testpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	createMethod = "/idempotency.test.TestService/Create"
+	updateMethod = "/idempotency.test.TestService/Update"
+)
+
+func testDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	// Every connection would get its own in-memory database.
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	require.NoError(t, Migrate(db))
+
+	return db
+}
+
+// countingHandler answers every call with a new id, or with the given
+// status when it is set.
+func countingHandler(calls *int64, status int64) grpc.UnaryHandler {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		*calls++
+
+		if status != 0 {
+			return &pb.CreateResponse{Status: status}, nil
+		}
+
+		return &pb.CreateResponse{Status: http.StatusCreated, Id: *calls}, nil
+	}
+}
+
+func call(interceptor grpc.UnaryServerInterceptor, method string, userId int64, key string, req interface{}, handler grpc.UnaryHandler) *pb.CreateResponse {
+	md := metadata.Pairs(MetadataKey, key, UserMetadataKey, strconv.FormatInt(userId, 10))
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+
+	res, _ := interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	created, _ := res.(*pb.CreateResponse)
+
+	return created
+}
+
+func TestReplaysTheFirstResponse(t *testing.T) {
+	interceptor := UnaryServerInterceptor(testDB(t), time.Hour, createMethod)
+	req := &pb.CreateRequest{Name: "a"}
+
+	var calls int64
+	first := call(interceptor, createMethod, 1, "k", req, countingHandler(&calls, 0))
+	second := call(interceptor, createMethod, 1, "k", req, countingHandler(&calls, 0))
+
+	assert.Equal(t, int64(1), calls)
+	assert.Equal(t, first.Id, second.Id)
+}
+
+func TestScopesKeysByUserAndMethod(t *testing.T) {
+	interceptor := UnaryServerInterceptor(testDB(t), time.Hour, createMethod, updateMethod)
+
+	var calls int64
+	mine := call(interceptor, createMethod, 1, "k", &pb.CreateRequest{Name: "a"}, countingHandler(&calls, 0))
+	theirs := call(interceptor, createMethod, 2, "k", &pb.CreateRequest{Name: "a"}, countingHandler(&calls, 0))
+	call(interceptor, updateMethod, 1, "k", &pb.CreateRequest{Name: "a"}, countingHandler(&calls, 0))
+
+	assert.Equal(t, int64(3), calls)
+	assert.NotEqual(t, mine.Id, theirs.Id)
+}
+
+func TestRejectsAKeyReusedForAnotherRequest(t *testing.T) {
+	interceptor := UnaryServerInterceptor(testDB(t), time.Hour, createMethod)
+
+	var calls int64
+	call(interceptor, createMethod, 1, "k", &pb.CreateRequest{Name: "a"}, countingHandler(&calls, 0))
+	reused := call(interceptor, createMethod, 1, "k", &pb.CreateRequest{Name: "b"}, countingHandler(&calls, 0))
+
+	assert.Equal(t, int64(1), calls)
+	assert.Equal(t, int64(http.StatusUnprocessableEntity), reused.Status)
+}
+
+func TestForgetsServerErrors(t *testing.T) {
+	interceptor := UnaryServerInterceptor(testDB(t), time.Hour, createMethod)
+	req := &pb.CreateRequest{Name: "a"}
+
+	var calls int64
+	failed := call(interceptor, createMethod, 1, "k", req, countingHandler(&calls, http.StatusInternalServerError))
+	retried := call(interceptor, createMethod, 1, "k", req, countingHandler(&calls, 0))
+
+	assert.Equal(t, int64(http.StatusInternalServerError), failed.Status)
+	assert.Equal(t, int64(http.StatusCreated), retried.Status)
+	assert.Equal(t, int64(2), calls)
+}
+
+func TestIgnoresCallsWithoutKey(t *testing.T) {
+	interceptor := UnaryServerInterceptor(testDB(t), time.Hour, createMethod)
+	req := &pb.CreateRequest{Name: "a"}
+
+	var calls int64
+	call(interceptor, createMethod, 1, "", req, countingHandler(&calls, 0))
+	call(interceptor, createMethod, 1, "", req, countingHandler(&calls, 0))
+
+	assert.Equal(t, int64(2), calls)
+}
+
+func TestForwardDerivesAKeyPerCall(t *testing.T) {
+	md := metadata.Pairs(MetadataKey, "k", UserMetadataKey, "7")
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+
+	first, _ := metadata.FromOutgoingContext(Forward(ctx, 1, 2))
+	second, _ := metadata.FromOutgoingContext(Forward(ctx, 1, 3))
+
+	assert.Equal(t, []string{"k/1/2"}, first.Get(MetadataKey))
+	assert.Equal(t, []string{"k/1/3"}, second.Get(MetadataKey))
+	assert.Equal(t, []string{"7"}, first.Get(UserMetadataKey))
+
+	_, ok := metadata.FromOutgoingContext(Forward(context.Background(), 1))
+	assert.False(t, ok)
+}
+
+func TestErrorResponse(t *testing.T) {
+	res, err := errorResponse(createMethod, http.StatusConflict, "busy")
+	require.NoError(t, err)
+
+	created, ok := res.(*pb.CreateResponse)
+	require.True(t, ok)
+	assert.Equal(t, int64(http.StatusConflict), created.Status)
+	assert.Equal(t, "busy", created.Error)
+}
@@ -0,0 +1,36 @@
+package idempotency
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Record is keyed by method, user and key together, so the same key sent by
+// two users or to two methods never shares a response.
+type Record struct {
+	Method      string `gorm:"primaryKey"`
+	UserId      int64  `gorm:"primaryKey;autoIncrement:false"`
+	Key         string `gorm:"primaryKey"`
+	RequestHash string `gorm:"not null"`
+	Response    []byte
+	Completed   bool      `gorm:"not null"`
+	CreatedAt   time.Time `gorm:"not null;index"`
+}
+
+func (Record) TableName() string {
+	return "idempotency_records"
+}
+
+// Migrate creates the table the interceptor stores responses in.
+func Migrate(db *gorm.DB) error {
+	// Records used to be keyed by the key alone. They only live for the
+	// retention window, so the old table is dropped rather than migrated.
+	if db.Migrator().HasTable(&Record{}) && !db.Migrator().HasColumn(&Record{}, "UserId") {
+		if err := db.Migrator().DropTable(&Record{}); err != nil {
+			return err
+		}
+	}
+
+	return db.AutoMigrate(&Record{})
+}
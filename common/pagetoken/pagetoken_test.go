@@ -0,0 +1,82 @@
+package pagetoken
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type cursor struct {
+	Value string `json:"v"`
+	Id    int64  `json:"id"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	signer := Signer{Key: []byte("secret")}
+
+	token, err := signer.Encode(cursor{Value: "a", Id: 1 << 60})
+	assert.NoError(t, err)
+
+	var decoded cursor
+	assert.NoError(t, signer.Decode(token, &decoded))
+	assert.Equal(t, cursor{Value: "a", Id: 1 << 60}, decoded)
+}
+
+func TestRejectsTamperedTokens(t *testing.T) {
+	signer := Signer{Key: []byte("secret")}
+
+	token, err := signer.Encode(cursor{Value: "a", Id: 1})
+	assert.NoError(t, err)
+
+	payload, signature, _ := strings.Cut(token, ".")
+	forged, _ := Signer{Key: []byte("other")}.Encode(cursor{Value: "a", Id: 2})
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	var decoded cursor
+	assert.ErrorIs(t, signer.Decode(forgedPayload+"."+signature, &decoded), ErrInvalid)
+	assert.ErrorIs(t, signer.Decode(forged, &decoded), ErrInvalid)
+	assert.ErrorIs(t, signer.Decode(payload, &decoded), ErrInvalid)
+	assert.ErrorIs(t, signer.Decode("", &decoded), ErrInvalid)
+}
+
+func TestRejectsExpiredTokens(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	signer := Signer{Key: []byte("secret"), TTL: time.Minute, now: func() time.Time { return now }}
+
+	token, err := signer.Encode(cursor{Value: "a", Id: 1})
+	assert.NoError(t, err)
+
+	var decoded cursor
+	now = now.Add(59 * time.Second)
+	assert.NoError(t, signer.Decode(token, &decoded))
+
+	now = now.Add(time.Second)
+	assert.ErrorIs(t, signer.Decode(token, &decoded), ErrExpired)
+
+	// Pushing the expiry out breaks the signature.
+	parts := strings.Split(token, ".")
+	assert.ErrorIs(t, signer.Decode(parts[0]+".0."+parts[2], &decoded), ErrInvalid)
+}
+
+func TestTokensWithoutTTLDoNotExpire(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	signer := Signer{Key: []byte("secret"), now: func() time.Time { return now }}
+
+	token, err := signer.Encode(cursor{Value: "a", Id: 1})
+	assert.NoError(t, err)
+
+	now = now.Add(24 * 365 * time.Hour)
+
+	var decoded cursor
+	assert.NoError(t, signer.Decode(token, &decoded))
+}
+
+func TestFilters(t *testing.T) {
+	price := int64(100)
+
+	assert.Equal(t, Filters("paid", int64(7)), Filters("paid", int64(7)))
+	assert.NotEqual(t, Filters("paid", int64(7)), Filters("paid", int64(8)))
+	assert.NotEqual(t, Filters(nil), Filters(&price))
+}
@@ -5,6 +5,8 @@ import (
 	"log"
 	"net"
 
+	"common/idempotency"
+	"common/pagetoken"
 	"google.golang.org/grpc"
 	"order-service/pkg/client"
 	"order-service/pkg/config"
@@ -39,9 +41,20 @@ func main() {
 	s := service.Server{
 		H:          h,
 		ProductSvc: productSvc,
+		PageTokens: pagetoken.Signer{Key: []byte(c.PageTokenSecret), TTL: c.PageTokenTTL},
 	}
 
-	grpcServer := grpc.NewServer()
+	go idempotency.PurgeExpired(h.DB, c.IdempotencyRetention)
+
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(idempotency.UnaryServerInterceptor(
+		h.DB,
+		c.IdempotencyRetention,
+		pb.OrderService_CreateOrder_FullMethodName,
+		pb.OrderService_UpdateOrder_FullMethodName,
+		pb.OrderService_DeleteOrder_FullMethodName,
+		pb.OrderService_CancelOrder_FullMethodName,
+		pb.OrderService_TransitionOrder_FullMethodName,
+	)))
 
 	pb.RegisterOrderServiceServer(grpcServer, &s)
 
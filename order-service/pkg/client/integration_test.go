@@ -50,6 +50,21 @@ func (m *mockProductServiceClient) DecreaseStock(ctx context.Context, req *pb.De
 	}, nil
 }
 
+func (m *mockProductServiceClient) ReleaseStock(ctx context.Context, req *pb.ReleaseStockRequest, opts ...grpc.CallOption) (*pb.ReleaseStockResponse, error) {
+	// Mock response based on the request id
+	if req.Id == 1 {
+		return &pb.ReleaseStockResponse{
+			Status: 1,
+			Error:  "",
+		}, nil
+	}
+	// Mock release stock failure response
+	return &pb.ReleaseStockResponse{
+		Status: 0,
+		Error:  "Stock decrease not found",
+	}, nil
+}
+
 func (m *mockProductServiceClient) CreateProduct(ctx context.Context, req *pb.CreateProductRequest, opts ...grpc.CallOption) (*pb.CreateProductResponse, error) {
 	// Mock response
 	return &pb.CreateProductResponse{
@@ -63,7 +78,7 @@ func TestFindOne(t *testing.T) {
 	mockClient := &mockProductServiceClient{}
 	productClient := ProductServiceClient{Client: mockClient}
 
-	res, err := productClient.FindOne(1)
+	res, err := productClient.FindOne(context.Background(), 1, 0)
 	assert.NoError(t, err)
 	assert.NotNil(t, res)
 	assert.Equal(t, int64(1), res.Data.Id)
@@ -76,7 +91,7 @@ func TestDecreaseStock(t *testing.T) {
 	mockClient := &mockProductServiceClient{}
 	productClient := ProductServiceClient{Client: mockClient}
 
-	res, err := productClient.DecreaseStock(1, 1)
+	res, err := productClient.DecreaseStock(context.Background(), 1, 0, 1, 1)
 	assert.NoError(t, err)
 	assert.NotNil(t, res)
 	assert.Equal(t, int64(1), res.Status)
@@ -105,7 +120,7 @@ func TestFindOne_NotFoun(t *testing.T) {
 	mockClient := &mockProductServiceClient{}
 	productClient := ProductServiceClient{Client: mockClient}
 
-	res, err := productClient.FindOne(2)
+	res, err := productClient.FindOne(context.Background(), 2, 0)
 	assert.NoError(t, err)
 	assert.NotNil(t, res)
 	assert.Equal(t, int64(0), res.Status)
@@ -116,9 +131,31 @@ func TestDecreaseStock_Failure(t *testing.T) {
 	mockClient := &mockProductServiceClient{}
 	productClient := ProductServiceClient{Client: mockClient}
 
-	res, err := productClient.DecreaseStock(2, 1)
+	res, err := productClient.DecreaseStock(context.Background(), 2, 0, 1, 1)
 	assert.NoError(t, err)
 	assert.NotNil(t, res)
 	assert.Equal(t, int64(0), res.Status)
 	assert.Equal(t, "Failed to decrease stock", res.Error)
 }
+
+func TestReleaseStock(t *testing.T) {
+	mockClient := &mockProductServiceClient{}
+	productClient := ProductServiceClient{Client: mockClient}
+
+	res, err := productClient.ReleaseStock(context.Background(), 1, 0, 1)
+	assert.NoError(t, err)
+	assert.NotNil(t, res)
+	assert.Equal(t, int64(1), res.Status)
+	assert.Empty(t, res.Error)
+}
+
+func TestReleaseStock_Failure(t *testing.T) {
+	mockClient := &mockProductServiceClient{}
+	productClient := ProductServiceClient{Client: mockClient}
+
+	res, err := productClient.ReleaseStock(context.Background(), 2, 0, 1)
+	assert.NoError(t, err)
+	assert.NotNil(t, res)
+	assert.Equal(t, int64(0), res.Status)
+	assert.Equal(t, "Stock decrease not found", res.Error)
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"common/idempotency"
 	"context"
 	"fmt"
 
@@ -26,22 +27,41 @@ func InitProductServiceClient(url string) ProductServiceClient {
 	return c
 }
 
-func (c *ProductServiceClient) FindOne(productId int64) (*pb.FindOneResponse, error) {
+// FindOne returns the product, or with a variantId the stock and price of
+// that variant of it.
+func (c *ProductServiceClient) FindOne(ctx context.Context, productId int64, variantId int64) (*pb.FindOneResponse, error) {
 	req := &pb.FindOneRequest{
-		Id: productId,
+		Id:        productId,
+		VariantId: variantId,
 	}
 
-	return c.Client.FindOne(context.Background(), req)
+	return c.Client.FindOne(ctx, req)
 }
 
-func (c *ProductServiceClient) DecreaseStock(productId int64, orderId int64) (*pb.DecreaseStockResponse, error) {
+// DecreaseStock reserves stock for an order line. The caller's idempotency
+// key is passed on, so the product service applies a retried call only once.
+func (c *ProductServiceClient) DecreaseStock(ctx context.Context, productId int64, variantId int64, orderId int64, quantity int64) (*pb.DecreaseStockResponse, error) {
 	req := &pb.DecreaseStockRequest{
-		Id:      productId,
-		OrderId: orderId,
+		Id:        productId,
+		VariantId: variantId,
+		OrderId:   orderId,
+		Quantity:  quantity,
 	}
 
-	return c.Client.DecreaseStock(context.Background(), req)
+	return c.Client.DecreaseStock(idempotency.Forward(ctx, orderId, productId, variantId), req)
 }
+
+// ReleaseStock returns the stock reserved for an order line.
+func (c *ProductServiceClient) ReleaseStock(ctx context.Context, productId int64, variantId int64, orderId int64) (*pb.ReleaseStockResponse, error) {
+	req := &pb.ReleaseStockRequest{
+		Id:        productId,
+		VariantId: variantId,
+		OrderId:   orderId,
+	}
+
+	return c.Client.ReleaseStock(idempotency.Forward(ctx, orderId, productId, variantId), req)
+}
+
 func (c *ProductServiceClient) CreateProduct(ctx context.Context, req *pb.CreateProductRequest) (*pb.CreateProductResponse, error) {
 	return c.Client.CreateProduct(ctx, req)
 }
@@ -1,9 +1,11 @@
 package client
 
 import (
+	"common/idempotency"
 	"context"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 	"testing"
 
 	"github.com/stretchr/testify/mock"
@@ -29,6 +31,11 @@ func (m *MockProductServiceClient) DecreaseStock(ctx context.Context, in *pb.Dec
 	return args.Get(0).(*pb.DecreaseStockResponse), args.Error(1)
 }
 
+func (m *MockProductServiceClient) ReleaseStock(ctx context.Context, in *pb.ReleaseStockRequest, opts ...grpc.CallOption) (*pb.ReleaseStockResponse, error) {
+	args := m.Called(ctx, in)
+	return args.Get(0).(*pb.ReleaseStockResponse), args.Error(1)
+}
+
 func TestFindOne_Success(t *testing.T) {
 	mockClient := new(MockProductServiceClient)
 	serviceClient := ProductServiceClient{Client: mockClient}
@@ -46,7 +53,7 @@ func TestFindOne_Success(t *testing.T) {
 
 	mockClient.On("FindOne", context.Background(), &pb.FindOneRequest{Id: productID}).Return(response, nil)
 
-	result, err := serviceClient.FindOne(productID)
+	result, err := serviceClient.FindOne(context.Background(), productID, 0)
 	require.NoError(t, err)
 	require.Equal(t, response, result)
 
@@ -65,7 +72,7 @@ func TestFindOne_NotFound(t *testing.T) {
 
 	mockClient.On("FindOne", context.Background(), &pb.FindOneRequest{Id: productID}).Return(response, nil)
 
-	result, err := serviceClient.FindOne(productID)
+	result, err := serviceClient.FindOne(context.Background(), productID, 0)
 	require.NoError(t, err)
 	require.Equal(t, response, result)
 
@@ -80,9 +87,9 @@ func TestDecreaseStock_Success(t *testing.T) {
 	orderID := int64(123)
 	response := &pb.DecreaseStockResponse{Status: 1}
 
-	mockClient.On("DecreaseStock", context.Background(), &pb.DecreaseStockRequest{Id: productID, OrderId: orderID}).Return(response, nil)
+	mockClient.On("DecreaseStock", context.Background(), &pb.DecreaseStockRequest{Id: productID, OrderId: orderID, Quantity: 2}).Return(response, nil)
 
-	result, err := serviceClient.DecreaseStock(productID, orderID)
+	result, err := serviceClient.DecreaseStock(context.Background(), productID, 0, orderID, 2)
 	require.NoError(t, err)
 	require.Equal(t, response, result)
 
@@ -100,9 +107,66 @@ func TestDecreaseStock_OutOfStock(t *testing.T) {
 		Error:  "Out of stock",
 	}
 
-	mockClient.On("DecreaseStock", context.Background(), &pb.DecreaseStockRequest{Id: productID, OrderId: orderID}).Return(response, nil)
+	mockClient.On("DecreaseStock", context.Background(), &pb.DecreaseStockRequest{Id: productID, OrderId: orderID, Quantity: 2}).Return(response, nil)
+
+	result, err := serviceClient.DecreaseStock(context.Background(), productID, 0, orderID, 2)
+	require.NoError(t, err)
+	require.Equal(t, response, result)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestDecreaseStock_Variant(t *testing.T) {
+	mockClient := new(MockProductServiceClient)
+	serviceClient := ProductServiceClient{Client: mockClient}
+
+	productID := int64(1)
+	variantID := int64(7)
+	orderID := int64(123)
+	response := &pb.DecreaseStockResponse{Status: 1}
+
+	mockClient.On("DecreaseStock", context.Background(), &pb.DecreaseStockRequest{Id: productID, VariantId: variantID, OrderId: orderID, Quantity: 2}).Return(response, nil)
+
+	result, err := serviceClient.DecreaseStock(context.Background(), productID, variantID, orderID, 2)
+	require.NoError(t, err)
+	require.Equal(t, response, result)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestDecreaseStock_ForwardsIdempotencyKey(t *testing.T) {
+	mockClient := new(MockProductServiceClient)
+	serviceClient := ProductServiceClient{Client: mockClient}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(idempotency.MetadataKey, "k", idempotency.UserMetadataKey, "7"))
+	response := &pb.DecreaseStockResponse{Status: 200}
+
+	forwarded := mock.MatchedBy(func(ctx context.Context) bool {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		return len(md.Get(idempotency.MetadataKey)) == 1 && md.Get(idempotency.MetadataKey)[0] == "k/123/1/5" &&
+			len(md.Get(idempotency.UserMetadataKey)) == 1 && md.Get(idempotency.UserMetadataKey)[0] == "7"
+	})
+
+	mockClient.On("DecreaseStock", forwarded, &pb.DecreaseStockRequest{Id: 1, VariantId: 5, OrderId: 123, Quantity: 2}).Return(response, nil)
+
+	result, err := serviceClient.DecreaseStock(ctx, 1, 5, 123, 2)
+	require.NoError(t, err)
+	require.Equal(t, response, result)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestReleaseStock_Success(t *testing.T) {
+	mockClient := new(MockProductServiceClient)
+	serviceClient := ProductServiceClient{Client: mockClient}
+
+	productID := int64(1)
+	orderID := int64(123)
+	response := &pb.ReleaseStockResponse{Status: 1}
+
+	mockClient.On("ReleaseStock", context.Background(), &pb.ReleaseStockRequest{Id: productID, OrderId: orderID}).Return(response, nil)
 
-	result, err := serviceClient.DecreaseStock(productID, orderID)
+	result, err := serviceClient.ReleaseStock(context.Background(), productID, 0, orderID)
 	require.NoError(t, err)
 	require.Equal(t, response, result)
 
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"common/idempotency"
 	"log"
 	"order-service/pkg/logger"
 
@@ -22,6 +23,41 @@ func Init(url string) Handler {
 	}
 
 	db.AutoMigrate(&models.Order{})
+	db.AutoMigrate(&models.OrderItem{})
+	db.AutoMigrate(&models.OrderTransition{})
+
+	if err := idempotency.Migrate(db); err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := migrateOrderLines(db); err != nil {
+		log.Fatalln(err)
+	}
 
 	return Handler{DB: db, Logger: logger.Info}
 }
+
+// migrateOrderLines moves the single product line that used to be stored on
+// the orders table into order_items.
+func migrateOrderLines(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.Order{}, "product_id") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`INSERT INTO order_items (order_id, product_id, quantity, unit_price, created_at, updated_at)
+			SELECT id, product_id, quantity, price / GREATEST(quantity, 1), created_at, updated_at FROM orders`).Error
+
+		if err != nil {
+			return err
+		}
+
+		for _, column := range []string{"product_id", "quantity", "price"} {
+			if err := tx.Migrator().DropColumn(&models.Order{}, column); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
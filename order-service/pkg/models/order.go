@@ -7,11 +7,20 @@ import (
 
 type Order struct {
 	ID        int64          `gorm:"primary_key"`
-	ProductID int64          `gorm:"not null"`
 	UserID    int64          `gorm:"not null"`
-	Quantity  int64          `gorm:"not null"`
-	Price     int64          `gorm:"not null"`
+	Status    string         `gorm:"not null;default:pending"`
+	Items     []OrderItem    `gorm:"foreignKey:OrderID"`
 	CreatedAt time.Time      `gorm:"not null"`
 	UpdatedAt time.Time      `gorm:"not null"`
 	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
+
+func (o *Order) Total() int64 {
+	var total int64
+
+	for _, item := range o.Items {
+		total += item.Quantity * item.UnitPrice
+	}
+
+	return total
+}
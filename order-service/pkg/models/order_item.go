@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type OrderItem struct {
+	ID        int64     `gorm:"primary_key"`
+	OrderID   int64     `gorm:"not null;index"`
+	ProductID int64     `gorm:"not null"`
+	VariantID int64     `gorm:"not null;default:0"`
+	Quantity  int64     `gorm:"not null"`
+	UnitPrice int64     `gorm:"not null"`
+	CreatedAt time.Time `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"not null"`
+}
@@ -0,0 +1,57 @@
+package models
+
+const (
+	StatusPending   = "pending"
+	StatusReserved  = "reserved"
+	StatusPaid      = "paid"
+	StatusShipped   = "shipped"
+	StatusDelivered = "delivered"
+	StatusCancelled = "cancelled"
+	StatusRefunded  = "refunded"
+)
+
+var orderTransitions = map[string][]string{
+	StatusPending:   {StatusReserved, StatusCancelled},
+	StatusReserved:  {StatusPaid, StatusCancelled},
+	StatusPaid:      {StatusShipped, StatusRefunded},
+	StatusShipped:   {StatusDelivered, StatusRefunded},
+	StatusDelivered: {StatusRefunded},
+}
+
+func IsOrderStatus(status string) bool {
+	switch status {
+	case StatusPending, StatusReserved, StatusPaid, StatusShipped, StatusDelivered, StatusCancelled, StatusRefunded:
+		return true
+	}
+
+	return false
+}
+
+func CanTransition(from string, to string) bool {
+	for _, status := range orderTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReleasesStock reports whether moving from one status to another hands the
+// reserved stock back, i.e. the goods never left the warehouse.
+func ReleasesStock(from string, to string) bool {
+	switch to {
+	case StatusCancelled:
+		return true
+	case StatusRefunded:
+		return from == StatusPaid
+	}
+
+	return false
+}
+
+// IsEditable reports whether the fields of an order may still be changed,
+// which is only the case while it is pending.
+func IsEditable(status string) bool {
+	return status == StatusPending
+}
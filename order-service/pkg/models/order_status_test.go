@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	assert.True(t, CanTransition(StatusPending, StatusReserved))
+	assert.True(t, CanTransition(StatusReserved, StatusPaid))
+	assert.True(t, CanTransition(StatusPaid, StatusShipped))
+	assert.True(t, CanTransition(StatusShipped, StatusDelivered))
+	assert.True(t, CanTransition(StatusReserved, StatusCancelled))
+	assert.True(t, CanTransition(StatusDelivered, StatusRefunded))
+
+	assert.False(t, CanTransition(StatusPending, StatusPaid))
+	assert.False(t, CanTransition(StatusShipped, StatusCancelled))
+	assert.False(t, CanTransition(StatusCancelled, StatusReserved))
+	assert.False(t, CanTransition(StatusRefunded, StatusPaid))
+	assert.False(t, CanTransition(StatusPending, "unknown"))
+}
+
+func TestReleasesStock(t *testing.T) {
+	assert.True(t, ReleasesStock(StatusReserved, StatusCancelled))
+	assert.True(t, ReleasesStock(StatusPaid, StatusRefunded))
+
+	assert.False(t, ReleasesStock(StatusShipped, StatusRefunded))
+	assert.False(t, ReleasesStock(StatusPaid, StatusShipped))
+}
+
+func TestIsEditable(t *testing.T) {
+	assert.True(t, IsEditable(StatusPending))
+
+	assert.False(t, IsEditable(StatusReserved))
+	assert.False(t, IsEditable(StatusPaid))
+	assert.False(t, IsEditable(StatusCancelled))
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type OrderTransition struct {
+	ID         int64     `gorm:"primary_key"`
+	OrderID    int64     `gorm:"not null;index"`
+	FromStatus string    `gorm:"not null"`
+	ToStatus   string    `gorm:"not null"`
+	Actor      string    `gorm:"not null"`
+	CreatedAt  time.Time `gorm:"not null"`
+}
@@ -1,34 +1,198 @@
 package service
 
 import (
+	"common/idempotency"
+	"common/pagetoken"
 	"context"
+	"errors"
+	"fmt"
 	"order-service/pkg/client"
 	"order-service/pkg/db"
 	"order-service/pkg/models"
 	"order-service/pkg/pb"
+	"strconv"
+	"strings"
 	"time"
+
+	"google.golang.org/grpc/metadata"
+	"gorm.io/gorm"
+)
+
+// ActorSystem is recorded in the order history for transitions the order
+// service makes on its own.
+const ActorSystem = "system"
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
 )
 
+var errOrderChanged = errors.New("Order was changed concurrently")
+
+// orderSortColumns whitelists the fields ListOrders can sort by.
+var orderSortColumns = map[string]string{
+	"":          "created_at",
+	"createdAt": "created_at",
+	"updatedAt": "updated_at",
+}
+
+// orderCursor is the keyset position encoded in a ListOrders page token: the
+// sort column value and id of the last order on the previous page, and the
+// sort and filters of the listing they were taken from.
+type orderCursor struct {
+	Sort    string    `json:"s"`
+	Filters string    `json:"f"`
+	Value   time.Time `json:"v"`
+	Id      int64     `json:"id"`
+}
+
 type Server struct {
 	H db.Handler
 	pb.UnimplementedOrderServiceServer
 	ProductSvc client.ProductServiceClient
+	PageTokens pagetoken.Signer
 }
 
 func (s *Server) CreateOrder(ctx context.Context, req *pb.CreateOrderRequest) (*pb.CreateOrderResponse, error) {
+	lines := req.Items
+
+	// A bare productId/quantity pair is shorthand for a single-line order.
+	if len(lines) == 0 && req.ProductId != 0 {
+		lines = []*pb.OrderItem{{ProductId: req.ProductId, VariantId: req.VariantId, Quantity: req.Quantity}}
+	}
+
+	if len(lines) == 0 {
+		return &pb.CreateOrderResponse{
+			Status: 400,
+			Error:  "Order has no items",
+		}, nil
+	}
+
+	// Stock is reserved once per order and product or variant, so repeated
+	// lines are merged into one.
+	type itemKey struct{ productId, variantId int64 }
+
+	var items []models.OrderItem
+	index := make(map[itemKey]int)
+
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			return &pb.CreateOrderResponse{
+				Status: 400,
+				Error:  "Quantity must be positive",
+			}, nil
+		}
+
+		key := itemKey{line.ProductId, line.VariantId}
+
+		if i, ok := index[key]; ok {
+			items[i].Quantity += line.Quantity
+			continue
+		}
+
+		index[key] = len(items)
+		items = append(items, models.OrderItem{
+			ProductID: line.ProductId,
+			VariantID: line.VariantId,
+			Quantity:  line.Quantity,
+		})
+	}
+
+	for i := range items {
+		product, err := s.ProductSvc.FindOne(ctx, items[i].ProductID, items[i].VariantID)
+
+		if err != nil {
+			return &pb.CreateOrderResponse{
+				Status: 502,
+				Error:  err.Error(),
+			}, nil
+		}
+
+		if product.Status >= 400 {
+			return &pb.CreateOrderResponse{
+				Status: product.Status,
+				Error:  product.Error,
+			}, nil
+		}
+
+		if product.Data.Stock < items[i].Quantity {
+			return &pb.CreateOrderResponse{
+				Status: 409,
+				Error:  fmt.Sprintf("Stock too low for product %d", items[i].ProductID),
+			}, nil
+		}
+
+		items[i].UnitPrice = product.Data.Price
+		items[i].CreatedAt = time.Now()
+		items[i].UpdatedAt = time.Now()
+	}
+
 	order := models.Order{
-		ProductID: req.ProductId,
 		UserID:    req.UserId,
-		Quantity:  req.Quantity,
-		Price:     100,
+		Status:    models.StatusPending,
+		Items:     items,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
-	if result := s.H.DB.Create(&order); result.Error != nil {
+	err := s.H.DB.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Create(&order); result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Create(&models.OrderTransition{
+			OrderID:   order.ID,
+			ToStatus:  models.StatusPending,
+			Actor:     fmt.Sprintf("user:%d", req.UserId),
+			CreatedAt: order.CreatedAt,
+		}).Error
+	})
+
+	if err != nil {
 		return &pb.CreateOrderResponse{
 			Status: 500,
-			Error:  result.Error.Error(),
+			Error:  err.Error(),
+		}, nil
+	}
+
+	for i, item := range order.Items {
+		res, err := s.ProductSvc.DecreaseStock(ctx, item.ProductID, item.VariantID, order.ID, item.Quantity)
+
+		if err == nil && res.Status == 200 {
+			continue
+		}
+
+		reserved := order.Items[:i]
+
+		// The failed decrease may still have been applied before the call failed.
+		if err != nil {
+			reserved = order.Items[:i+1]
+		}
+
+		// The reservation is undone even when the caller has gone away.
+		s.releaseStock(context.WithoutCancel(ctx), order.ID, reserved)
+		s.compensateOrder(order)
+
+		if err != nil {
+			return &pb.CreateOrderResponse{
+				Status: 502,
+				Error:  err.Error(),
+			}, nil
+		}
+
+		return &pb.CreateOrderResponse{
+			Status: res.Status,
+			Error:  res.Error,
+		}, nil
+	}
+
+	if status, err := s.transitionOrder(ctx, &order, models.StatusReserved, ActorSystem); err != nil {
+		s.releaseStock(context.WithoutCancel(ctx), order.ID, order.Items)
+		s.compensateOrder(order)
+
+		return &pb.CreateOrderResponse{
+			Status: status,
+			Error:  err.Error(),
 		}, nil
 	}
 
@@ -38,9 +202,118 @@ func (s *Server) CreateOrder(ctx context.Context, req *pb.CreateOrderRequest) (*
 	}, nil
 }
 
+// transitionOrder moves an order to a new status and records the change in
+// its history. The update only applies while the order is still in the status
+// it was read with, so two concurrent transitions cannot both succeed.
+func (s *Server) transitionOrder(ctx context.Context, order *models.Order, to string, actor string) (int64, error) {
+	from := order.Status
+
+	if from == to {
+		// Repeating a cancellation retries a stock release that failed before.
+		if to == models.StatusCancelled {
+			if err := s.releaseStock(ctx, order.ID, order.Items); err != nil {
+				return 502, err
+			}
+		}
+
+		return 200, nil
+	}
+
+	if !models.CanTransition(from, to) {
+		return 409, fmt.Errorf("Cannot move order from %s to %s", from, to)
+	}
+
+	now := time.Now()
+
+	err := s.H.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Order{}).
+			Where("id = ? AND status = ?", order.ID, from).
+			Updates(map[string]interface{}{"status": to, "updated_at": now})
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errOrderChanged
+		}
+
+		return tx.Create(&models.OrderTransition{
+			OrderID:    order.ID,
+			FromStatus: from,
+			ToStatus:   to,
+			Actor:      actor,
+			CreatedAt:  now,
+		}).Error
+	})
+
+	if errors.Is(err, errOrderChanged) {
+		return 409, err
+	}
+
+	if err != nil {
+		return 500, err
+	}
+
+	order.Status = to
+	order.UpdatedAt = now
+
+	if models.ReleasesStock(from, to) {
+		if err := s.releaseStock(ctx, order.ID, order.Items); err != nil {
+			return 502, err
+		}
+	}
+
+	return 200, nil
+}
+
+// releaseStock returns the stock reserved for the given order lines to the
+// product service.
+func (s *Server) releaseStock(ctx context.Context, orderId int64, items []models.OrderItem) error {
+	var failed error
+
+	for _, item := range items {
+		res, err := s.ProductSvc.ReleaseStock(ctx, item.ProductID, item.VariantID, orderId)
+
+		if err == nil && res.Status != 200 && res.Status != 404 {
+			err = errors.New(res.Error)
+		}
+
+		if err != nil {
+			s.H.Logger.Printf("failed to release stock of product %d (variant %d) for order %d: %v", item.ProductID, item.VariantID, orderId, err)
+
+			if failed == nil {
+				failed = err
+			}
+		}
+	}
+
+	return failed
+}
+
+// compensateOrder removes an order whose stock could not be reserved, so no
+// order is ever left behind for a product that was never decreased.
+func (s *Server) compensateOrder(order models.Order) {
+	err := s.H.DB.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Where(&models.OrderItem{OrderID: order.ID}).Delete(&models.OrderItem{}); result.Error != nil {
+			return result.Error
+		}
+
+		if result := tx.Where(&models.OrderTransition{OrderID: order.ID}).Delete(&models.OrderTransition{}); result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Unscoped().Delete(&models.Order{}, order.ID).Error
+	})
+
+	if err != nil {
+		s.H.Logger.Printf("failed to remove order %d after stock reservation failed: %v", order.ID, err)
+	}
+}
+
 func (s *Server) GetOrder(ctx context.Context, req *pb.GetOrderRequest) (*pb.GetOrderResponse, error) {
 	var order models.Order
-	if result := s.H.DB.First(&order, req.Id); result.Error != nil {
+	if result := s.H.DB.Preload("Items").First(&order, req.Id); result.Error != nil {
 		return &pb.GetOrderResponse{
 			Status: 404,
 			Error:  result.Error.Error(),
@@ -49,55 +322,82 @@ func (s *Server) GetOrder(ctx context.Context, req *pb.GetOrderRequest) (*pb.Get
 
 	return &pb.GetOrderResponse{
 		Status: 200,
-		Order: &pb.Order{
-			Id:        order.ID,
-			ProductId: order.ProductID,
-			UserId:    order.UserID,
-			Quantity:  order.Quantity,
-			Price:     order.Price,
-			CreatedAt: order.CreatedAt.Format(time.RFC3339),
-			UpdatedAt: order.UpdatedAt.Format(time.RFC3339),
-		},
+		Order:  toOrderData(order),
 	}, nil
 }
 
+// UpdateOrder hands an order over to another user. Like every field edit it
+// is rejected once the order has left pending.
 func (s *Server) UpdateOrder(ctx context.Context, req *pb.UpdateOrderRequest) (*pb.UpdateOrderResponse, error) {
 	var order models.Order
-	if result := s.H.DB.First(&order, req.Id); result.Error != nil {
+	if result := s.H.DB.Preload("Items").First(&order, req.Id); result.Error != nil {
 		return &pb.UpdateOrderResponse{
 			Status: 404,
 			Error:  result.Error.Error(),
 		}, nil
 	}
 
-	order.ProductID = req.ProductId
-	order.Quantity = req.Quantity
+	if !models.IsEditable(order.Status) {
+		return &pb.UpdateOrderResponse{
+			Status: 409,
+			Error:  "Order can no longer be edited",
+		}, nil
+	}
+
 	order.UserID = req.UserId
 	order.UpdatedAt = time.Now()
 
-	if result := s.H.DB.Save(&order); result.Error != nil {
+	// Only the user changes, and only while the status is still the one the
+	// order was read with, so an edit never undoes a concurrent transition.
+	result := s.H.DB.Model(&models.Order{}).
+		Where("id = ? AND status = ?", order.ID, order.Status).
+		Updates(map[string]interface{}{"user_id": order.UserID, "updated_at": order.UpdatedAt})
+
+	if result.Error != nil {
 		return &pb.UpdateOrderResponse{
 			Status: 500,
 			Error:  result.Error.Error(),
 		}, nil
 	}
 
+	if result.RowsAffected == 0 {
+		return &pb.UpdateOrderResponse{
+			Status: 409,
+			Error:  errOrderChanged.Error(),
+		}, nil
+	}
+
 	return &pb.UpdateOrderResponse{
 		Status: 200,
-		Order: &pb.Order{
-			Id:        order.ID,
-			ProductId: order.ProductID,
-			UserId:    order.UserID,
-			Quantity:  order.Quantity,
-			Price:     order.Price,
-			CreatedAt: order.CreatedAt.Format(time.RFC3339),
-			UpdatedAt: order.UpdatedAt.Format(time.RFC3339),
-		},
+		Order:  toOrderData(order),
 	}, nil
 }
 
+// DeleteOrder removes an order. An order that can still be cancelled is
+// cancelled first, so the stock it reserved goes back to the product service
+// before the order disappears. For an order that is already cancelled the
+// release is retried, in case it failed when the order was cancelled.
 func (s *Server) DeleteOrder(ctx context.Context, req *pb.DeleteOrderRequest) (*pb.DeleteOrderResponse, error) {
-	if result := s.H.DB.Delete(&models.Order{}, req.Id); result.Error != nil {
+	var order models.Order
+	if result := s.H.DB.Preload("Items").First(&order, req.Id); result.Error != nil {
+		return &pb.DeleteOrderResponse{
+			Status:  404,
+			Error:   result.Error.Error(),
+			Success: false,
+		}, nil
+	}
+
+	if order.Status == models.StatusCancelled || models.CanTransition(order.Status, models.StatusCancelled) {
+		if status, err := s.transitionOrder(ctx, &order, models.StatusCancelled, ActorSystem); err != nil {
+			return &pb.DeleteOrderResponse{
+				Status:  status,
+				Error:   err.Error(),
+				Success: false,
+			}, nil
+		}
+	}
+
+	if result := s.H.DB.Delete(&models.Order{}, order.ID); result.Error != nil {
 		return &pb.DeleteOrderResponse{
 			Status:  500,
 			Error:   result.Error.Error(),
@@ -110,3 +410,302 @@ func (s *Server) DeleteOrder(ctx context.Context, req *pb.DeleteOrderRequest) (*
 		Success: true,
 	}, nil
 }
+
+func (s *Server) CancelOrder(ctx context.Context, req *pb.CancelOrderRequest) (*pb.CancelOrderResponse, error) {
+	var order models.Order
+	if result := s.H.DB.Preload("Items").First(&order, req.Id); result.Error != nil {
+		return &pb.CancelOrderResponse{
+			Status: 404,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	if status, err := s.transitionOrder(ctx, &order, models.StatusCancelled, callerActor(ctx)); err != nil {
+		return &pb.CancelOrderResponse{
+			Status: status,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	return &pb.CancelOrderResponse{
+		Status: 200,
+	}, nil
+}
+
+func (s *Server) TransitionOrder(ctx context.Context, req *pb.TransitionOrderRequest) (*pb.TransitionOrderResponse, error) {
+	if !models.IsOrderStatus(req.Status) {
+		return &pb.TransitionOrderResponse{
+			Status: 400,
+			Error:  fmt.Sprintf("Unknown order status %q", req.Status),
+		}, nil
+	}
+
+	var order models.Order
+	if result := s.H.DB.Preload("Items").First(&order, req.Id); result.Error != nil {
+		return &pb.TransitionOrderResponse{
+			Status: 404,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	if status, err := s.transitionOrder(ctx, &order, req.Status, callerActor(ctx)); err != nil {
+		return &pb.TransitionOrderResponse{
+			Status: status,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	return &pb.TransitionOrderResponse{
+		Status: 200,
+		Order:  toOrderData(order),
+	}, nil
+}
+
+func (s *Server) GetOrderHistory(ctx context.Context, req *pb.GetOrderHistoryRequest) (*pb.GetOrderHistoryResponse, error) {
+	var order models.Order
+	if result := s.H.DB.First(&order, req.Id); result.Error != nil {
+		return &pb.GetOrderHistoryResponse{
+			Status: 404,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	var history []models.OrderTransition
+	if result := s.H.DB.Where(&models.OrderTransition{OrderID: order.ID}).Order("created_at, id").Find(&history); result.Error != nil {
+		return &pb.GetOrderHistoryResponse{
+			Status: 500,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	transitions := make([]*pb.OrderTransition, 0, len(history))
+
+	for _, transition := range history {
+		transitions = append(transitions, &pb.OrderTransition{
+			FromStatus: transition.FromStatus,
+			ToStatus:   transition.ToStatus,
+			Actor:      transition.Actor,
+			CreatedAt:  transition.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	return &pb.GetOrderHistoryResponse{
+		Status:      200,
+		Transitions: transitions,
+	}, nil
+}
+
+func (s *Server) ListOrders(ctx context.Context, req *pb.ListOrdersRequest) (*pb.ListOrdersResponse, error) {
+	column, ok := orderSortColumns[req.SortBy]
+
+	if !ok {
+		return &pb.ListOrdersResponse{
+			Status: 400,
+			Error:  fmt.Sprintf("Cannot sort orders by %q", req.SortBy),
+		}, nil
+	}
+
+	direction := strings.ToLower(req.SortOrder)
+
+	switch direction {
+	case "":
+		direction = "desc"
+	case "asc", "desc":
+	default:
+		return &pb.ListOrdersResponse{
+			Status: 400,
+			Error:  "Sort order must be asc or desc",
+		}, nil
+	}
+
+	query := s.H.DB.Model(&models.Order{})
+
+	if req.UserId != 0 {
+		query = query.Where("user_id = ?", req.UserId)
+	}
+
+	if req.Status != "" {
+		if !models.IsOrderStatus(req.Status) {
+			return &pb.ListOrdersResponse{
+				Status: 400,
+				Error:  fmt.Sprintf("Unknown order status %q", req.Status),
+			}, nil
+		}
+
+		query = query.Where("status = ?", req.Status)
+	}
+
+	if req.CreatedAfter != "" {
+		createdAfter, err := time.Parse(time.RFC3339, req.CreatedAfter)
+
+		if err != nil {
+			return &pb.ListOrdersResponse{
+				Status: 400,
+				Error:  "createdAfter must be an RFC 3339 timestamp",
+			}, nil
+		}
+
+		query = query.Where("created_at >= ?", createdAfter)
+	}
+
+	if req.CreatedBefore != "" {
+		createdBefore, err := time.Parse(time.RFC3339, req.CreatedBefore)
+
+		if err != nil {
+			return &pb.ListOrdersResponse{
+				Status: 400,
+				Error:  "createdBefore must be an RFC 3339 timestamp",
+			}, nil
+		}
+
+		query = query.Where("created_at < ?", createdBefore)
+	}
+
+	var total int64
+
+	if req.IncludeTotal {
+		if result := query.Count(&total); result.Error != nil {
+			return &pb.ListOrdersResponse{
+				Status: 500,
+				Error:  result.Error.Error(),
+			}, nil
+		}
+	}
+
+	sort := column + " " + direction
+	filters := pagetoken.Filters(req.UserId, req.Status, req.CreatedAfter, req.CreatedBefore)
+
+	if req.PageToken != "" {
+		var cursor orderCursor
+
+		if err := s.PageTokens.Decode(req.PageToken, &cursor); err != nil {
+			return &pb.ListOrdersResponse{
+				Status: 400,
+				Error:  err.Error(),
+			}, nil
+		}
+
+		if cursor.Sort != sort {
+			return &pb.ListOrdersResponse{
+				Status: 400,
+				Error:  "Page token does not match the sort order",
+			}, nil
+		}
+
+		if cursor.Filters != filters {
+			return &pb.ListOrdersResponse{
+				Status: 400,
+				Error:  "Page token does not match the filters",
+			}, nil
+		}
+
+		operator := "<"
+		if direction == "asc" {
+			operator = ">"
+		}
+
+		query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, operator), cursor.Value, cursor.Id)
+	}
+
+	pageSize := int(req.PageSize)
+
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	var orders []models.Order
+
+	result := query.
+		Preload("Items").
+		Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).
+		Limit(pageSize + 1).
+		Find(&orders)
+
+	if result.Error != nil {
+		return &pb.ListOrdersResponse{
+			Status: 500,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	var nextPageToken string
+
+	if len(orders) > pageSize {
+		orders = orders[:pageSize]
+		last := orders[pageSize-1]
+
+		cursor := orderCursor{Sort: sort, Filters: filters, Value: last.CreatedAt, Id: last.ID}
+		if column == "updated_at" {
+			cursor.Value = last.UpdatedAt
+		}
+
+		token, err := s.PageTokens.Encode(cursor)
+
+		if err != nil {
+			return &pb.ListOrdersResponse{
+				Status: 500,
+				Error:  err.Error(),
+			}, nil
+		}
+
+		nextPageToken = token
+	}
+
+	data := make([]*pb.Order, 0, len(orders))
+
+	for _, order := range orders {
+		data = append(data, toOrderData(order))
+	}
+
+	return &pb.ListOrdersResponse{
+		Status:        200,
+		Orders:        data,
+		NextPageToken: nextPageToken,
+		Total:         total,
+	}, nil
+}
+
+// callerActor names the user the gateway authenticated for the call, as
+// CreateOrder does, or the system for calls made without one.
+func callerActor(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(idempotency.UserMetadataKey)
+
+	if len(values) == 0 {
+		return ActorSystem
+	}
+
+	userId, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil || userId == 0 {
+		return ActorSystem
+	}
+
+	return fmt.Sprintf("user:%d", userId)
+}
+
+func toOrderData(order models.Order) *pb.Order {
+	items := make([]*pb.OrderItem, 0, len(order.Items))
+
+	for _, item := range order.Items {
+		items = append(items, &pb.OrderItem{
+			ProductId: item.ProductID,
+			VariantId: item.VariantID,
+			Quantity:  item.Quantity,
+			UnitPrice: item.UnitPrice,
+		})
+	}
+
+	return &pb.Order{
+		Id:        order.ID,
+		UserId:    order.UserID,
+		Items:     items,
+		Total:     order.Total(),
+		Status:    order.Status,
+		CreatedAt: order.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: order.UpdatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"common/pagetoken"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"order-service/pkg/client"
+	"order-service/pkg/db"
+	"order-service/pkg/logger"
+	"order-service/pkg/models"
+	"order-service/pkg/pb"
+)
+
+type mockProductClient struct {
+	mock.Mock
+}
+
+func (m *mockProductClient) CreateProduct(ctx context.Context, in *pb.CreateProductRequest, opts ...grpc.CallOption) (*pb.CreateProductResponse, error) {
+	args := m.Called(in)
+	return args.Get(0).(*pb.CreateProductResponse), args.Error(1)
+}
+
+func (m *mockProductClient) FindOne(ctx context.Context, in *pb.FindOneRequest, opts ...grpc.CallOption) (*pb.FindOneResponse, error) {
+	args := m.Called(in)
+	return args.Get(0).(*pb.FindOneResponse), args.Error(1)
+}
+
+func (m *mockProductClient) DecreaseStock(ctx context.Context, in *pb.DecreaseStockRequest, opts ...grpc.CallOption) (*pb.DecreaseStockResponse, error) {
+	args := m.Called(in)
+	res, _ := args.Get(0).(*pb.DecreaseStockResponse)
+	return res, args.Error(1)
+}
+
+func (m *mockProductClient) ReleaseStock(ctx context.Context, in *pb.ReleaseStockRequest, opts ...grpc.CallOption) (*pb.ReleaseStockResponse, error) {
+	args := m.Called(in)
+	return args.Get(0).(*pb.ReleaseStockResponse), args.Error(1)
+}
+
+func testServer(t *testing.T, products *mockProductClient) *Server {
+	conn, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	// Every connection would get its own in-memory database.
+	sqlDB, err := conn.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	require.NoError(t, conn.AutoMigrate(&models.Order{}, &models.OrderItem{}, &models.OrderTransition{}))
+
+	return &Server{
+		H:          db.Handler{DB: conn, Logger: logger.Info},
+		ProductSvc: client.ProductServiceClient{Client: products},
+	}
+}
+
+// inStock makes every product available at a price of 100.
+func inStock(products *mockProductClient, productIds ...int64) {
+	for _, id := range productIds {
+		products.On("FindOne", &pb.FindOneRequest{Id: id}).
+			Return(&pb.FindOneResponse{Status: http.StatusOK, Data: &pb.FindOneData{Id: id, Stock: 10, Price: 100}}, nil)
+	}
+}
+
+func decrease(orderId int64, productId int64, quantity int64) *pb.DecreaseStockRequest {
+	return &pb.DecreaseStockRequest{Id: productId, OrderId: orderId, Quantity: quantity}
+}
+
+func TestCreateOrderReservesEveryLine(t *testing.T) {
+	products := new(mockProductClient)
+	s := testServer(t, products)
+
+	inStock(products, 1, 2)
+	products.On("DecreaseStock", decrease(1, 1, 3)).Return(&pb.DecreaseStockResponse{Status: http.StatusOK}, nil)
+	products.On("DecreaseStock", decrease(1, 2, 1)).Return(&pb.DecreaseStockResponse{Status: http.StatusOK}, nil)
+
+	res, err := s.CreateOrder(context.Background(), &pb.CreateOrderRequest{
+		UserId: 7,
+		Items: []*pb.OrderItem{
+			{ProductId: 1, Quantity: 2},
+			{ProductId: 2, Quantity: 1},
+			{ProductId: 1, Quantity: 1},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(http.StatusCreated), res.Status, res.Error)
+
+	var order models.Order
+	require.NoError(t, s.H.DB.Preload("Items").First(&order, res.Id).Error)
+
+	assert.Equal(t, models.StatusReserved, order.Status)
+	assert.Len(t, order.Items, 2)
+	assert.Equal(t, int64(400), order.Total())
+
+	var history []models.OrderTransition
+	s.H.DB.Where("order_id = ?", order.ID).Order("id").Find(&history)
+
+	require.Len(t, history, 2)
+	assert.Equal(t, "user:7", history[0].Actor)
+	assert.Equal(t, models.StatusReserved, history[1].ToStatus)
+
+	products.AssertNotCalled(t, "ReleaseStock", mock.Anything)
+	products.AssertExpectations(t)
+}
+
+func TestCreateOrderReleasesReservedLinesWhenOneIsRefused(t *testing.T) {
+	products := new(mockProductClient)
+	s := testServer(t, products)
+
+	inStock(products, 1, 2, 3)
+	products.On("DecreaseStock", decrease(1, 1, 1)).Return(&pb.DecreaseStockResponse{Status: http.StatusOK}, nil)
+	products.On("DecreaseStock", decrease(1, 2, 1)).Return(&pb.DecreaseStockResponse{Status: http.StatusOK}, nil)
+	products.On("DecreaseStock", decrease(1, 3, 1)).Return(&pb.DecreaseStockResponse{Status: http.StatusConflict, Error: "Stock too low"}, nil)
+	products.On("ReleaseStock", &pb.ReleaseStockRequest{Id: 1, OrderId: 1}).Return(&pb.ReleaseStockResponse{Status: http.StatusOK}, nil)
+	products.On("ReleaseStock", &pb.ReleaseStockRequest{Id: 2, OrderId: 1}).Return(&pb.ReleaseStockResponse{Status: http.StatusOK}, nil)
+
+	res, err := s.CreateOrder(context.Background(), &pb.CreateOrderRequest{
+		UserId: 7,
+		Items: []*pb.OrderItem{
+			{ProductId: 1, Quantity: 1},
+			{ProductId: 2, Quantity: 1},
+			{ProductId: 3, Quantity: 1},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(http.StatusConflict), res.Status)
+	assert.Equal(t, "Stock too low", res.Error)
+
+	products.AssertNotCalled(t, "ReleaseStock", &pb.ReleaseStockRequest{Id: 3, OrderId: 1})
+	products.AssertExpectations(t)
+
+	assertNoOrderLeft(t, s)
+}
+
+func TestCreateOrderReleasesTheLineWhoseDecreaseFailed(t *testing.T) {
+	products := new(mockProductClient)
+	s := testServer(t, products)
+
+	inStock(products, 1, 2)
+	products.On("DecreaseStock", decrease(1, 1, 1)).Return(&pb.DecreaseStockResponse{Status: http.StatusOK}, nil)
+	products.On("DecreaseStock", decrease(1, 2, 1)).Return(nil, errors.New("connection reset"))
+	products.On("ReleaseStock", &pb.ReleaseStockRequest{Id: 1, OrderId: 1}).Return(&pb.ReleaseStockResponse{Status: http.StatusOK}, nil)
+	products.On("ReleaseStock", &pb.ReleaseStockRequest{Id: 2, OrderId: 1}).Return(&pb.ReleaseStockResponse{Status: http.StatusNotFound}, nil)
+
+	res, err := s.CreateOrder(context.Background(), &pb.CreateOrderRequest{
+		UserId: 7,
+		Items: []*pb.OrderItem{
+			{ProductId: 1, Quantity: 1},
+			{ProductId: 2, Quantity: 1},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(http.StatusBadGateway), res.Status)
+
+	products.AssertExpectations(t)
+
+	assertNoOrderLeft(t, s)
+}
+
+func TestListOrdersRejectsATokenOfOtherFilters(t *testing.T) {
+	s := testServer(t, new(mockProductClient))
+	s.PageTokens = pagetoken.Signer{Key: []byte("secret")}
+
+	for i := 0; i < 3; i++ {
+		now := time.Now().Add(time.Duration(i) * time.Second)
+		require.NoError(t, s.H.DB.Create(&models.Order{UserID: 7, Status: models.StatusReserved, CreatedAt: now, UpdatedAt: now}).Error)
+	}
+
+	first, err := s.ListOrders(context.Background(), &pb.ListOrdersRequest{UserId: 7, Status: models.StatusReserved, PageSize: 2})
+	require.NoError(t, err)
+	require.Equal(t, int64(http.StatusOK), first.Status, first.Error)
+	require.NotEmpty(t, first.NextPageToken)
+
+	next, _ := s.ListOrders(context.Background(), &pb.ListOrdersRequest{UserId: 7, Status: models.StatusReserved, PageSize: 2, PageToken: first.NextPageToken})
+	assert.Equal(t, int64(http.StatusOK), next.Status, next.Error)
+	assert.Len(t, next.Orders, 1)
+
+	other, _ := s.ListOrders(context.Background(), &pb.ListOrdersRequest{UserId: 7, Status: models.StatusPaid, PageSize: 2, PageToken: first.NextPageToken})
+	assert.Equal(t, int64(http.StatusBadRequest), other.Status)
+
+	otherUser, _ := s.ListOrders(context.Background(), &pb.ListOrdersRequest{UserId: 8, Status: models.StatusReserved, PageSize: 2, PageToken: first.NextPageToken})
+	assert.Equal(t, int64(http.StatusBadRequest), otherUser.Status)
+}
+
+func assertNoOrderLeft(t *testing.T, s *Server) {
+	var orders, items, transitions int64
+
+	s.H.DB.Unscoped().Model(&models.Order{}).Count(&orders)
+	s.H.DB.Model(&models.OrderItem{}).Count(&items)
+	s.H.DB.Model(&models.OrderTransition{}).Count(&transitions)
+
+	assert.Zero(t, orders)
+	assert.Zero(t, items)
+	assert.Zero(t, transitions)
+}
@@ -5,6 +5,8 @@ import (
 	"log"
 	"net"
 
+	"common/idempotency"
+	"common/pagetoken"
 	"google.golang.org/grpc"
 	"product/pkg/config"
 	"product/pkg/db"
@@ -30,10 +32,27 @@ func main() {
 	fmt.Println("Product Svc on", c.Port)
 
 	s := services.Server{
-		H: h,
+		H:          h,
+		PageTokens: pagetoken.Signer{Key: []byte(c.PageTokenSecret), TTL: c.PageTokenTTL},
 	}
 
-	grpcServer := grpc.NewServer()
+	go idempotency.PurgeExpired(h.DB, c.IdempotencyRetention)
+
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(idempotency.UnaryServerInterceptor(
+		h.DB,
+		c.IdempotencyRetention,
+		pb.ProductService_CreateProduct_FullMethodName,
+		pb.ProductService_UpdateProduct_FullMethodName,
+		pb.ProductService_DeleteProduct_FullMethodName,
+		pb.ProductService_DecreaseStock_FullMethodName,
+		pb.ProductService_ReleaseStock_FullMethodName,
+		pb.ProductService_CreateCategory_FullMethodName,
+		pb.ProductService_UpdateCategory_FullMethodName,
+		pb.ProductService_DeleteCategory_FullMethodName,
+		pb.ProductService_CreateVariant_FullMethodName,
+		pb.ProductService_UpdateVariant_FullMethodName,
+		pb.ProductService_DeleteVariant_FullMethodName,
+	)))
 
 	pb.RegisterProductServiceServer(grpcServer, &s)
 
@@ -2,10 +2,19 @@ package services_test
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"product/pkg/db"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"common/idempotency"
+	"common/pagetoken"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 	"product/pkg/models"
 	pb "product/pkg/pb"
 	"product/pkg/services"
@@ -161,3 +170,474 @@ func TestIntegrationListProducts(t *testing.T) {
 		t.Error("Expected non-empty product list")
 	}
 }
+
+func TestIntegrationDecreaseStockQuantity(t *testing.T) {
+	server := services.Server{H: handler}
+
+	testProduct := models.Product{Name: "Test Product", Stock: 10, Price: 100}
+	handler.DB.Create(&testProduct)
+
+	req := &pb.DecreaseStockRequest{Id: testProduct.Id, OrderId: 1, Quantity: 3}
+
+	resp, err := server.DecreaseStock(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed to decrease stock: %v", err)
+	}
+
+	if resp.Status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got %d", http.StatusOK, resp.Status)
+	}
+
+	resp, err = server.DecreaseStock(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed to decrease stock: %v", err)
+	}
+
+	if resp.Status != http.StatusConflict {
+		t.Errorf("Expected repeated decrease to return status %d, but got %d", http.StatusConflict, resp.Status)
+	}
+
+	var product models.Product
+	handler.DB.First(&product, testProduct.Id)
+
+	if product.Stock != 7 {
+		t.Errorf("Expected stock to be 7, but got %d", product.Stock)
+	}
+
+	var log models.StockDecreaseLog
+	if err := handler.DB.Where(&models.StockDecreaseLog{OrderId: 1, ProductRefer: testProduct.Id}).First(&log).Error; err != nil {
+		t.Fatalf("Expected stock decrease log, but got error: %v", err)
+	}
+
+	if log.Quantity != 3 {
+		t.Errorf("Expected logged quantity to be 3, but got %d", log.Quantity)
+	}
+}
+
+func TestIntegrationDecreaseStockConcurrent(t *testing.T) {
+	server := services.Server{H: handler}
+
+	testProduct := models.Product{Name: "Test Product", Stock: 10, Price: 100}
+	handler.DB.Create(&testProduct)
+
+	const orders = 50
+
+	var wg sync.WaitGroup
+	var succeeded int64
+
+	for i := 1; i <= orders; i++ {
+		wg.Add(1)
+
+		go func(orderId int64) {
+			defer wg.Done()
+
+			resp, err := server.DecreaseStock(context.Background(), &pb.DecreaseStockRequest{
+				Id:       testProduct.Id,
+				OrderId:  orderId,
+				Quantity: 1,
+			})
+
+			if err == nil && resp.Status == http.StatusOK {
+				atomic.AddInt64(&succeeded, 1)
+			}
+		}(int64(i))
+	}
+
+	wg.Wait()
+
+	if succeeded != testProduct.Stock {
+		t.Errorf("Expected %d successful decreases, but got %d", testProduct.Stock, succeeded)
+	}
+
+	var product models.Product
+	handler.DB.First(&product, testProduct.Id)
+
+	if product.Stock != 0 {
+		t.Errorf("Expected stock to be 0, but got %d", product.Stock)
+	}
+}
+
+func TestIntegrationReleaseStock(t *testing.T) {
+	server := services.Server{H: handler}
+
+	testProduct := models.Product{Name: "Test Product", Stock: 10, Price: 100}
+	handler.DB.Create(&testProduct)
+
+	server.DecreaseStock(context.Background(), &pb.DecreaseStockRequest{Id: testProduct.Id, OrderId: 1, Quantity: 4})
+
+	req := &pb.ReleaseStockRequest{Id: testProduct.Id, OrderId: 1}
+
+	for i := 0; i < 2; i++ {
+		resp, err := server.ReleaseStock(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Failed to release stock: %v", err)
+		}
+
+		if resp.Status != http.StatusOK {
+			t.Fatalf("Expected status %d, but got %d", http.StatusOK, resp.Status)
+		}
+	}
+
+	var product models.Product
+	handler.DB.First(&product, testProduct.Id)
+
+	if product.Stock != testProduct.Stock {
+		t.Errorf("Expected stock to be restored to %d, but got %d", testProduct.Stock, product.Stock)
+	}
+
+	resp, err := server.ReleaseStock(context.Background(), &pb.ReleaseStockRequest{Id: testProduct.Id, OrderId: 2})
+	if err != nil {
+		t.Fatalf("Failed to release stock: %v", err)
+	}
+
+	if resp.Status != http.StatusNotFound {
+		t.Errorf("Expected status %d for an order without reserved stock, but got %d", http.StatusNotFound, resp.Status)
+	}
+}
+
+func TestIntegrationIdempotentCreateProduct(t *testing.T) {
+	server := services.Server{H: handler}
+	interceptor := idempotency.UnaryServerInterceptor(handler.DB, time.Hour, pb.ProductService_CreateProduct_FullMethodName)
+	info := &grpc.UnaryServerInfo{FullMethod: pb.ProductService_CreateProduct_FullMethodName}
+
+	createProduct := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return server.CreateProduct(ctx, req.(*pb.CreateProductRequest))
+	}
+
+	key := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(idempotency.MetadataKey, key))
+	req := &pb.CreateProductRequest{Name: "Test Product", Stock: 10, Price: 100}
+
+	first, err := interceptor(ctx, req, info, createProduct)
+	if err != nil {
+		t.Fatalf("Failed to create product: %v", err)
+	}
+
+	replayed, err := interceptor(ctx, req, info, createProduct)
+	if err != nil {
+		t.Fatalf("Failed to replay create product: %v", err)
+	}
+
+	if first.(*pb.CreateProductResponse).Id != replayed.(*pb.CreateProductResponse).Id {
+		t.Errorf("Expected replay to return product %d, but got %d", first.(*pb.CreateProductResponse).Id, replayed.(*pb.CreateProductResponse).Id)
+	}
+
+	other, err := interceptor(ctx, &pb.CreateProductRequest{Name: "Other Product", Stock: 1, Price: 1}, info, createProduct)
+	if err != nil {
+		t.Fatalf("Failed to call create product: %v", err)
+	}
+
+	if other.(*pb.CreateProductResponse).Status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d for a reused key, but got %d", http.StatusUnprocessableEntity, other.(*pb.CreateProductResponse).Status)
+	}
+}
+
+func TestIntegrationCategoriesAndTags(t *testing.T) {
+	server := services.Server{H: handler}
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	// Строим дерево: корень -> подкатегория
+	root, err := server.CreateCategory(context.Background(), &pb.CreateCategoryRequest{Name: "Root " + suffix})
+	if err != nil {
+		t.Fatalf("Failed to create category: %v", err)
+	}
+
+	if root.Status != http.StatusCreated {
+		t.Fatalf("Expected status %d, but got %d", http.StatusCreated, root.Status)
+	}
+
+	child, err := server.CreateCategory(context.Background(), &pb.CreateCategoryRequest{Name: "Child " + suffix, ParentId: root.Id})
+	if err != nil {
+		t.Fatalf("Failed to create category: %v", err)
+	}
+
+	if child.Status != http.StatusCreated {
+		t.Fatalf("Expected status %d, but got %d", http.StatusCreated, child.Status)
+	}
+
+	// Категорию нельзя сделать потомком самой себя
+	cycle, _ := server.UpdateCategory(context.Background(), &pb.UpdateCategoryRequest{Id: root.Id, Name: "Root " + suffix, ParentId: child.Id})
+	if cycle.Status != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a cycle, but got %d", http.StatusBadRequest, cycle.Status)
+	}
+
+	tag := "Tag-" + suffix
+
+	created, err := server.CreateProduct(context.Background(), &pb.CreateProductRequest{
+		Name:        "Test Product",
+		Stock:       10,
+		Price:       100,
+		CategoryIds: []int64{child.Id},
+		Tags:        []string{tag, " " + tag + " "},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create product: %v", err)
+	}
+
+	if created.Status != http.StatusCreated {
+		t.Fatalf("Expected status %d, but got %d", http.StatusCreated, created.Status)
+	}
+
+	read, _ := server.ReadProduct(context.Background(), &pb.ReadProductRequest{Id: created.Id})
+	if len(read.Product.CategoryIds) != 1 || len(read.Product.Tags) != 1 || read.Product.Tags[0] != strings.ToLower(tag) {
+		t.Errorf("Expected one category and tag %q, but got %+v", strings.ToLower(tag), read.Product)
+	}
+
+	// Без подкатегорий товар в корне не виден
+	direct, _ := server.ListProducts(context.Background(), &pb.ListProductsRequest{CategoryId: root.Id})
+	if len(direct.Products) != 0 {
+		t.Errorf("Expected no products directly in the root category, but got %d", len(direct.Products))
+	}
+
+	nested, _ := server.ListProducts(context.Background(), &pb.ListProductsRequest{CategoryId: root.Id, IncludeSubcategories: true, Tag: tag})
+	if len(nested.Products) != 1 || nested.Products[0].Id != created.Id {
+		t.Errorf("Expected product %d in the category tree, but got %+v", created.Id, nested.Products)
+	}
+
+	// Категорию с подкатегориями удалить нельзя
+	deleted, _ := server.DeleteCategory(context.Background(), &pb.DeleteCategoryRequest{Id: root.Id})
+	if deleted.Status != http.StatusConflict {
+		t.Errorf("Expected status %d, but got %d", http.StatusConflict, deleted.Status)
+	}
+
+	unknown, _ := server.CreateProduct(context.Background(), &pb.CreateProductRequest{Name: "Test Product", CategoryIds: []int64{-1}})
+	if unknown.Status != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown category, but got %d", http.StatusBadRequest, unknown.Status)
+	}
+}
+
+func TestIntegrationSearchProducts(t *testing.T) {
+	server := services.Server{H: handler}
+	word := fmt.Sprintf("zq%d", time.Now().UnixNano())
+
+	handler.DB.Create(&models.Product{Name: "Lamp " + word, Description: "A desk lamp", Stock: 1, Price: 10})
+	handler.DB.Create(&models.Product{Name: "Chair", Description: "Goes well with the " + word + " lamp", Stock: 1, Price: 10})
+
+	// Ищем по префиксу слова; совпадение в названии должно быть выше
+	resp, err := server.SearchProducts(context.Background(), &pb.SearchProductsRequest{Query: word[:len(word)-2]})
+	if err != nil {
+		t.Fatalf("Failed to search products: %v", err)
+	}
+
+	if resp.Status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got %d", http.StatusOK, resp.Status)
+	}
+
+	if resp.Total != 2 || len(resp.Results) != 2 {
+		t.Fatalf("Expected 2 results, but got %d of %d", len(resp.Results), resp.Total)
+	}
+
+	if resp.Results[0].Product.Name != "Lamp "+word {
+		t.Errorf("Expected the name match first, but got %+v", resp.Results[0].Product)
+	}
+
+	if !strings.Contains(resp.Results[1].Snippet, "<b>") {
+		t.Errorf("Expected a highlighted snippet, but got %q", resp.Results[1].Snippet)
+	}
+
+	// Постраничный вывод
+	page, _ := server.SearchProducts(context.Background(), &pb.SearchProductsRequest{Query: word, Page: 2, PageSize: 1})
+	if len(page.Results) != 1 || page.Results[0].Product.Name != "Chair" {
+		t.Errorf("Expected the second result on page 2, but got %+v", page.Results)
+	}
+
+	empty, _ := server.SearchProducts(context.Background(), &pb.SearchProductsRequest{Query: " & !"})
+	if empty.Status != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an empty query, but got %d", http.StatusBadRequest, empty.Status)
+	}
+}
+
+func TestIntegrationListProductsSortAndRanges(t *testing.T) {
+	server := services.Server{H: handler}
+	name := fmt.Sprintf("Sorted %d", time.Now().UnixNano())
+
+	handler.DB.Create(&models.Product{Name: name + " a", Stock: 5, Price: 300})
+	handler.DB.Create(&models.Product{Name: name + " b", Stock: 0, Price: 300})
+	handler.DB.Create(&models.Product{Name: name + " c", Stock: 7, Price: 100})
+
+	// Несколько ключей сортировки: цена по убыванию, затем имя по возрастанию
+	minPrice, maxStock := int64(200), int64(10)
+	resp, err := server.ListProducts(context.Background(), &pb.ListProductsRequest{
+		Filter:   name,
+		SortBy:   "price desc, name asc",
+		MinPrice: &minPrice,
+		MaxStock: &maxStock,
+	})
+	if err != nil {
+		t.Fatalf("Failed to list products: %v", err)
+	}
+
+	if resp.Status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got %d", http.StatusOK, resp.Status)
+	}
+
+	if len(resp.Products) != 2 || resp.Products[0].Name != name+" a" || resp.Products[1].Name != name+" b" {
+		t.Errorf("Expected products a and b in order, but got %+v", resp.Products)
+	}
+
+	// Нулевая граница тоже учитывается
+	zero := int64(0)
+	outOfStock, _ := server.ListProducts(context.Background(), &pb.ListProductsRequest{Filter: name, MaxStock: &zero})
+	if len(outOfStock.Products) != 1 || outOfStock.Products[0].Name != name+" b" {
+		t.Errorf("Expected only product b to be out of stock, but got %+v", outOfStock.Products)
+	}
+
+	// Неизвестные поля и направления отклоняются
+	for _, sortBy := range []string{"price; DROP TABLE products", "secret", "name sideways"} {
+		invalid, _ := server.ListProducts(context.Background(), &pb.ListProductsRequest{SortBy: sortBy})
+		if invalid.Status != http.StatusBadRequest {
+			t.Errorf("Expected status %d for sort %q, but got %d", http.StatusBadRequest, sortBy, invalid.Status)
+		}
+	}
+}
+
+func TestIntegrationListProductsPageTokens(t *testing.T) {
+	server := services.Server{H: handler, PageTokens: pagetoken.Signer{Key: []byte("test")}}
+	name := fmt.Sprintf("Paged %d", time.Now().UnixNano())
+
+	for _, price := range []int64{300, 100, 200, 100, 300} {
+		handler.DB.Create(&models.Product{Name: name, Stock: 1, Price: price})
+	}
+
+	// Листаем по две записи, сортируя по цене по убыванию
+	req := &pb.ListProductsRequest{Filter: name, SortBy: "price desc", PageSize: 2, IncludeTotal: true}
+
+	var prices []int64
+	for page := 0; page < 5; page++ {
+		resp, err := server.ListProducts(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Failed to list products: %v", err)
+		}
+
+		if resp.Status != http.StatusOK {
+			t.Fatalf("Expected status %d, but got %d: %s", http.StatusOK, resp.Status, resp.Error)
+		}
+
+		if resp.Total != 5 {
+			t.Errorf("Expected total 5, but got %d", resp.Total)
+		}
+
+		for _, product := range resp.Products {
+			prices = append(prices, product.Price)
+		}
+
+		if resp.NextPageToken == "" {
+			break
+		}
+
+		req.PageToken = resp.NextPageToken
+	}
+
+	if fmt.Sprint(prices) != "[300 300 200 100 100]" {
+		t.Errorf("Expected every product once in price order, but got %v", prices)
+	}
+
+	// Подделанный токен и смена сортировки отклоняются
+	first, _ := server.ListProducts(context.Background(), &pb.ListProductsRequest{Filter: name, SortBy: "price desc", PageSize: 2})
+
+	tampered, _ := server.ListProducts(context.Background(), &pb.ListProductsRequest{Filter: name, SortBy: "price desc", PageToken: first.NextPageToken + "x"})
+	if tampered.Status != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a tampered token, but got %d", http.StatusBadRequest, tampered.Status)
+	}
+
+	resorted, _ := server.ListProducts(context.Background(), &pb.ListProductsRequest{Filter: name, SortBy: "name", PageToken: first.NextPageToken})
+	if resorted.Status != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a different sort, but got %d", http.StatusBadRequest, resorted.Status)
+	}
+
+	if first.Total != 0 {
+		t.Errorf("Expected no total unless asked for, but got %d", first.Total)
+	}
+}
+
+func TestIntegrationSkusAndVariants(t *testing.T) {
+	server := services.Server{H: handler}
+	sku := fmt.Sprintf("SKU-%d", time.Now().UnixNano())
+
+	created, err := server.CreateProduct(context.Background(), &pb.CreateProductRequest{Name: "Shirt", Sku: sku, Stock: 1, Price: 1000})
+	if err != nil {
+		t.Fatalf("Failed to create product: %v", err)
+	}
+
+	if created.Status != http.StatusCreated {
+		t.Fatalf("Expected status %d, but got %d", http.StatusCreated, created.Status)
+	}
+
+	// Повторный SKU отклоняется, в том числе для варианта
+	duplicate, _ := server.CreateProduct(context.Background(), &pb.CreateProductRequest{Name: "Other", Sku: sku})
+	if duplicate.Status != http.StatusConflict {
+		t.Errorf("Expected status %d for a duplicate SKU, but got %d", http.StatusConflict, duplicate.Status)
+	}
+
+	clash, _ := server.CreateVariant(context.Background(), &pb.CreateVariantRequest{ProductId: created.Id, Sku: sku})
+	if clash.Status != http.StatusConflict {
+		t.Errorf("Expected status %d for a variant with the product SKU, but got %d", http.StatusConflict, clash.Status)
+	}
+
+	price := int64(1200)
+	variant, err := server.CreateVariant(context.Background(), &pb.CreateVariantRequest{
+		ProductId: created.Id,
+		Sku:       sku + "-XL",
+		Size:      "XL",
+		Color:     "red",
+		Price:     &price,
+		Stock:     3,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create variant: %v", err)
+	}
+
+	if variant.Status != http.StatusCreated {
+		t.Fatalf("Expected status %d, but got %d", http.StatusCreated, variant.Status)
+	}
+
+	// Поиск по SKU варианта возвращает его цену и остаток
+	found, _ := server.FindOne(context.Background(), &pb.FindOneRequest{Sku: sku + "-XL"})
+	if found.Status != http.StatusOK || found.Data.Id != created.Id || found.Data.VariantId != variant.Id || found.Data.Price != price || found.Data.Stock != 3 {
+		t.Errorf("Expected variant %d of product %d, but got %+v", variant.Id, created.Id, found)
+	}
+
+	byProductSku, _ := server.FindOne(context.Background(), &pb.FindOneRequest{Sku: sku})
+	if byProductSku.Status != http.StatusOK || byProductSku.Data.Id != created.Id || byProductSku.Data.VariantId != 0 {
+		t.Errorf("Expected product %d, but got %+v", created.Id, byProductSku)
+	}
+
+	// По id товара и варианта, как ищет сервис заказов
+	byVariantId, _ := server.FindOne(context.Background(), &pb.FindOneRequest{Id: created.Id, VariantId: variant.Id})
+	if byVariantId.Status != http.StatusOK || byVariantId.Data.VariantId != variant.Id || byVariantId.Data.Stock != 3 {
+		t.Errorf("Expected variant %d of product %d, but got %+v", variant.Id, created.Id, byVariantId)
+	}
+
+	otherVariant, _ := server.FindOne(context.Background(), &pb.FindOneRequest{Id: created.Id, VariantId: variant.Id + 1000000})
+	if otherVariant.Status != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown variant, but got %d", http.StatusNotFound, otherVariant.Status)
+	}
+
+	// Списание и возврат остатка варианта не трогают остаток товара
+	orderId := time.Now().UnixNano()
+	decreased, _ := server.DecreaseStock(context.Background(), &pb.DecreaseStockRequest{Id: created.Id, VariantId: variant.Id, OrderId: orderId, Quantity: 2})
+	if decreased.Status != http.StatusOK {
+		t.Fatalf("Expected status %d, but got %d: %s", http.StatusOK, decreased.Status, decreased.Error)
+	}
+
+	tooMany, _ := server.DecreaseStock(context.Background(), &pb.DecreaseStockRequest{Id: created.Id, OrderId: orderId, Quantity: 2})
+	if tooMany.Status != http.StatusConflict {
+		t.Errorf("Expected status %d for the product stock, but got %d", http.StatusConflict, tooMany.Status)
+	}
+
+	var stored models.Variant
+	handler.DB.First(&stored, variant.Id)
+	if stored.Stock != 1 {
+		t.Errorf("Expected variant stock 1, but got %d", stored.Stock)
+	}
+
+	released, _ := server.ReleaseStock(context.Background(), &pb.ReleaseStockRequest{Id: created.Id, VariantId: variant.Id, OrderId: orderId})
+	if released.Status != http.StatusOK {
+		t.Errorf("Expected status %d, but got %d", http.StatusOK, released.Status)
+	}
+
+	handler.DB.First(&stored, variant.Id)
+	if stored.Stock != 3 {
+		t.Errorf("Expected variant stock 3 after release, but got %d", stored.Stock)
+	}
+}
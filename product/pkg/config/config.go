@@ -1,10 +1,17 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 type Config struct {
-	Port  string `mapstructure:"PORT"`
-	DBUrl string `mapstructure:"DB_URL"`
+	Port                 string        `mapstructure:"PORT"`
+	DBUrl                string        `mapstructure:"DB_URL"`
+	IdempotencyRetention time.Duration `mapstructure:"IDEMPOTENCY_RETENTION"`
+	PageTokenSecret      string        `mapstructure:"PAGE_TOKEN_SECRET"`
+	PageTokenTTL         time.Duration `mapstructure:"PAGE_TOKEN_TTL"`
 }
 
 func LoadConfig() (config Config, err error) {
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"common/idempotency"
+
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"log"
@@ -18,9 +20,35 @@ func Init(url string) Handler {
 		log.Fatalln(err)
 	}
 
+	db.AutoMigrate(&models.Category{})
+	db.AutoMigrate(&models.Tag{})
 	db.AutoMigrate(&models.Product{})
+
+	// search_vector is maintained by Postgres, so it is not part of the model.
+	if result := db.Exec(`ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(description, '')), 'B')
+	) STORED`); result.Error != nil {
+		log.Fatalln("Failed to add search_vector:", result.Error)
+	}
+
+	if result := db.Exec("CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN (search_vector)"); result.Error != nil {
+		log.Fatalln("Failed to index search_vector:", result.Error)
+	}
+
+	db.AutoMigrate(&models.Variant{})
 	db.AutoMigrate(&models.StockDecreaseLog{})
 
+	// Superseded by idx_stock_decrease_order_variant, which lets an order
+	// reserve several variants of one product.
+	if db.Migrator().HasIndex(&models.StockDecreaseLog{}, "idx_stock_decrease_order_product") {
+		db.Migrator().DropIndex(&models.StockDecreaseLog{}, "idx_stock_decrease_order_product")
+	}
+
+	if err := idempotency.Migrate(db); err != nil {
+		log.Fatalln(err)
+	}
+
 	return Handler{db}
 }
 func (h *Handler) Close() {
@@ -0,0 +1,8 @@
+package models
+
+// Category is a node of the category tree; root categories have no parent.
+type Category struct {
+	Id       int64  `json:"id" gorm:"primaryKey"`
+	Name     string `json:"name" gorm:"not null;uniqueIndex:idx_category_parent_name"`
+	ParentId *int64 `json:"parent_id" gorm:"index;uniqueIndex:idx_category_parent_name"`
+}
@@ -3,7 +3,12 @@ package models
 type Product struct {
 	Id                int64            `json:"id" gorm:"primaryKey"`
 	Name              string           `json:"name"`
+	Description       string           `json:"description"`
+	Sku               string           `json:"sku" gorm:"not null;default:'';uniqueIndex:idx_products_sku,where:sku <> ''"`
 	Stock             int64            `json:"stock"`
 	Price             int64            `json:"price"`
 	StockDecreaseLogs StockDecreaseLog `gorm:"foreignKey:ProductRefer"`
+	Categories        []Category       `json:"categories" gorm:"many2many:product_categories"`
+	Tags              []Tag            `json:"tags" gorm:"many2many:product_tags"`
+	Variants          []Variant        `json:"variants" gorm:"foreignKey:ProductId"`
 }
@@ -1,7 +1,12 @@
 package models
 
+// StockDecreaseLog records a stock reservation of an order. VariantId is 0
+// when the stock of the product itself was decreased.
 type StockDecreaseLog struct {
 	Id           int64 `json:"id" gorm:"primaryKey"`
-	OrderId      int64 `json:"order_id"`
-	ProductRefer int64 `json:"product_id"`
+	OrderId      int64 `json:"order_id" gorm:"uniqueIndex:idx_stock_decrease_order_variant"`
+	ProductRefer int64 `json:"product_id" gorm:"uniqueIndex:idx_stock_decrease_order_variant"`
+	VariantId    int64 `json:"variant_id" gorm:"not null;default:0;uniqueIndex:idx_stock_decrease_order_variant"`
+	Quantity     int64 `json:"quantity"`
+	Released     bool  `json:"released"`
 }
@@ -0,0 +1,7 @@
+package models
+
+// Tag is a free-form label. Names are stored lower case.
+type Tag struct {
+	Id   int64  `json:"id" gorm:"primaryKey"`
+	Name string `json:"name" gorm:"uniqueIndex;not null"`
+}
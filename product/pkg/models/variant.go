@@ -0,0 +1,23 @@
+package models
+
+// Variant is a sellable version of a product, such as a size or colour, with
+// its own SKU and stock.
+type Variant struct {
+	Id        int64  `json:"id" gorm:"primaryKey"`
+	ProductId int64  `json:"product_id" gorm:"not null;index"`
+	Sku       string `json:"sku" gorm:"not null;uniqueIndex"`
+	Size      string `json:"size"`
+	Color     string `json:"color"`
+	// Price overrides the price of the product when set.
+	Price *int64 `json:"price"`
+	Stock int64  `json:"stock"`
+}
+
+// EffectivePrice is the price the variant sells at.
+func (v Variant) EffectivePrice(product Product) int64 {
+	if v.Price != nil {
+		return *v.Price
+	}
+
+	return product.Price
+}
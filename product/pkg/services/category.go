@@ -0,0 +1,384 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"product/pkg/models"
+	pb "product/pkg/pb"
+)
+
+func toCategoryData(category models.Category) *pb.CategoryData {
+	data := &pb.CategoryData{
+		Id:   category.Id,
+		Name: category.Name,
+	}
+
+	if category.ParentId != nil {
+		data.ParentId = *category.ParentId
+	}
+
+	return data
+}
+
+// parentRef turns the parentId of a request into the column value, nil
+// meaning a root category.
+func parentRef(parentId int64) *int64 {
+	if parentId == 0 {
+		return nil
+	}
+
+	return &parentId
+}
+
+// siblingExists reports whether another category under the same parent
+// already has the name. Checked here as well as by the unique index since
+// Postgres lets any number of roots share a name.
+func siblingExists(tx *gorm.DB, name string, parentId *int64, id int64) bool {
+	query := tx.Model(&models.Category{}).Where("name = ? AND id <> ?", name, id)
+
+	if parentId == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *parentId)
+	}
+
+	var count int64
+	query.Count(&count)
+
+	return count > 0
+}
+
+// categoryMoveLock is the advisory lock key that serializes moving categories.
+// Two moves checked against each other's old parents could otherwise close a
+// cycle between them.
+const categoryMoveLock = 0x63617465 // "cate"
+
+// categoryTree returns the id of the category followed by the ids of all
+// categories below it. UNION rather than UNION ALL ends the walk even if the
+// tree should contain a cycle.
+func categoryTree(tx *gorm.DB, id int64) ([]int64, error) {
+	var ids []int64
+
+	err := tx.Raw(`WITH RECURSIVE tree AS (
+		SELECT id FROM categories WHERE id = ?
+		UNION
+		SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+	) SELECT id FROM tree`, id).Scan(&ids).Error
+
+	return ids, err
+}
+
+func (s *Server) CreateCategory(ctx context.Context, req *pb.CreateCategoryRequest) (*pb.CreateCategoryResponse, error) {
+	name := strings.TrimSpace(req.Name)
+
+	if name == "" {
+		return &pb.CreateCategoryResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Name is required",
+		}, nil
+	}
+
+	category := models.Category{
+		Name:     name,
+		ParentId: parentRef(req.ParentId),
+	}
+
+	if category.ParentId != nil {
+		if result := s.H.DB.First(&models.Category{}, req.ParentId); result.Error != nil {
+			return &pb.CreateCategoryResponse{
+				Status: http.StatusBadRequest,
+				Error:  "Parent category not found",
+			}, nil
+		}
+	}
+
+	if siblingExists(s.H.DB, name, category.ParentId, 0) {
+		return &pb.CreateCategoryResponse{
+			Status: http.StatusConflict,
+			Error:  "Category already exists",
+		}, nil
+	}
+
+	if result := s.H.DB.Create(&category); result.Error != nil {
+		return &pb.CreateCategoryResponse{
+			Status: http.StatusConflict,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	return &pb.CreateCategoryResponse{
+		Status: http.StatusCreated,
+		Id:     category.Id,
+	}, nil
+}
+
+func (s *Server) ReadCategory(ctx context.Context, req *pb.ReadCategoryRequest) (*pb.ReadCategoryResponse, error) {
+	var category models.Category
+
+	if result := s.H.DB.First(&category, req.Id); result.Error != nil {
+		return &pb.ReadCategoryResponse{
+			Status: http.StatusNotFound,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	var children []models.Category
+
+	if result := s.H.DB.Where("parent_id = ?", category.Id).Order("name").Find(&children); result.Error != nil {
+		return &pb.ReadCategoryResponse{
+			Status: http.StatusInternalServerError,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	var childData []*pb.CategoryData
+	for _, child := range children {
+		childData = append(childData, toCategoryData(child))
+	}
+
+	return &pb.ReadCategoryResponse{
+		Status:   http.StatusOK,
+		Category: toCategoryData(category),
+		Children: childData,
+	}, nil
+}
+
+func (s *Server) ListCategories(ctx context.Context, req *pb.ListCategoriesRequest) (*pb.ListCategoriesResponse, error) {
+	var categories []models.Category
+
+	if result := s.H.DB.Order("id").Find(&categories); result.Error != nil {
+		return &pb.ListCategoriesResponse{
+			Status: http.StatusInternalServerError,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	var data []*pb.CategoryData
+	for _, category := range categories {
+		data = append(data, toCategoryData(category))
+	}
+
+	return &pb.ListCategoriesResponse{
+		Status:     http.StatusOK,
+		Categories: data,
+	}, nil
+}
+
+// UpdateCategory renames and moves a category. A category cannot be moved
+// below itself or one of its descendants.
+func (s *Server) UpdateCategory(ctx context.Context, req *pb.UpdateCategoryRequest) (*pb.UpdateCategoryResponse, error) {
+	name := strings.TrimSpace(req.Name)
+
+	if name == "" {
+		return &pb.UpdateCategoryResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Name is required",
+		}, nil
+	}
+
+	var res *pb.UpdateCategoryResponse
+
+	err := s.H.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var category models.Category
+
+		// Held until the transaction ends, so the descendants checked below
+		// cannot change before the move is saved.
+		if req.ParentId != 0 {
+			if result := tx.Exec("SELECT pg_advisory_xact_lock(?)", categoryMoveLock); result.Error != nil {
+				return result.Error
+			}
+		}
+
+		if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&category, req.Id); result.Error != nil {
+			res = &pb.UpdateCategoryResponse{
+				Status: http.StatusNotFound,
+				Error:  result.Error.Error(),
+			}
+			return result.Error
+		}
+
+		category.Name = name
+		category.ParentId = parentRef(req.ParentId)
+
+		if category.ParentId != nil {
+			if result := tx.First(&models.Category{}, req.ParentId); result.Error != nil {
+				res = &pb.UpdateCategoryResponse{
+					Status: http.StatusBadRequest,
+					Error:  "Parent category not found",
+				}
+				return result.Error
+			}
+
+			tree, err := categoryTree(tx, category.Id)
+
+			if err != nil {
+				return err
+			}
+
+			for _, id := range tree {
+				if id == req.ParentId {
+					res = &pb.UpdateCategoryResponse{
+						Status: http.StatusBadRequest,
+						Error:  "Category cannot be moved below itself",
+					}
+					return errors.New(res.Error)
+				}
+			}
+		}
+
+		if siblingExists(tx, name, category.ParentId, category.Id) {
+			res = &pb.UpdateCategoryResponse{
+				Status: http.StatusConflict,
+				Error:  "Category already exists",
+			}
+			return errors.New(res.Error)
+		}
+
+		return tx.Save(&category).Error
+	})
+
+	if err != nil {
+		if res != nil {
+			return res, nil
+		}
+
+		return &pb.UpdateCategoryResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	return &pb.UpdateCategoryResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+// DeleteCategory removes a leaf category and takes it off its products.
+// Categories that still have children are refused.
+func (s *Server) DeleteCategory(ctx context.Context, req *pb.DeleteCategoryRequest) (*pb.DeleteCategoryResponse, error) {
+	var res *pb.DeleteCategoryResponse
+
+	err := s.H.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var category models.Category
+
+		if result := tx.First(&category, req.Id); result.Error != nil {
+			res = &pb.DeleteCategoryResponse{
+				Status: http.StatusNotFound,
+				Error:  result.Error.Error(),
+			}
+			return result.Error
+		}
+
+		var children int64
+		tx.Model(&models.Category{}).Where("parent_id = ?", category.Id).Count(&children)
+
+		if children > 0 {
+			res = &pb.DeleteCategoryResponse{
+				Status: http.StatusConflict,
+				Error:  "Category has subcategories",
+			}
+			return errors.New(res.Error)
+		}
+
+		if result := tx.Exec("DELETE FROM product_categories WHERE category_id = ?", category.Id); result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Delete(&category).Error
+	})
+
+	if err != nil {
+		if res != nil {
+			return res, nil
+		}
+
+		return &pb.DeleteCategoryResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	return &pb.DeleteCategoryResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+// findCategories loads the categories of a product request. Unknown ids are
+// reported back so the caller can answer with a 400.
+func findCategories(tx *gorm.DB, ids []int64) ([]models.Category, error) {
+	var categories []models.Category
+
+	if len(ids) == 0 {
+		return categories, nil
+	}
+
+	if result := tx.Where("id IN ?", ids).Find(&categories); result.Error != nil {
+		return nil, result.Error
+	}
+
+	seen := make(map[int64]bool)
+	for _, id := range ids {
+		seen[id] = true
+	}
+
+	if len(categories) != len(seen) {
+		return nil, errUnknownCategory
+	}
+
+	return categories, nil
+}
+
+var errUnknownCategory = errors.New("Category not found")
+
+// normalizeTags trims and lower-cases tag names, dropping blanks and
+// duplicates.
+func normalizeTags(names []string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		if name == "" || seen[name] {
+			continue
+		}
+
+		seen[name] = true
+		tags = append(tags, name)
+	}
+
+	return tags
+}
+
+// findOrCreateTags returns the tags with the given names, creating the ones
+// that do not exist yet.
+func findOrCreateTags(tx *gorm.DB, names []string) ([]models.Tag, error) {
+	var tags []models.Tag
+
+	names = normalizeTags(names)
+
+	if len(names) == 0 {
+		return tags, nil
+	}
+
+	for _, name := range names {
+		tags = append(tags, models.Tag{Name: name})
+	}
+
+	if result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags); result.Error != nil {
+		return nil, result.Error
+	}
+
+	tags = nil
+
+	if result := tx.Where("name IN ?", names).Order("name").Find(&tags); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"common/pagetoken"
+	"encoding/json"
+	"strings"
+
+	"product/pkg/models"
+	pb "product/pkg/pb"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// productCursor is the keyset position encoded in a ListProducts page token:
+// the sort key values of the last product on the previous page. Sort holds
+// the order clause the values belong to and Filters the filters of the
+// listing.
+type productCursor struct {
+	Sort    string        `json:"s"`
+	Filters string        `json:"f"`
+	Values  []interface{} `json:"v"`
+}
+
+// productFilters identifies the filters of a ListProducts request.
+func productFilters(req *pb.ListProductsRequest) string {
+	return pagetoken.Filters(req.Filter, req.CategoryId, req.IncludeSubcategories, strings.ToLower(strings.TrimSpace(req.Tag)),
+		req.MinPrice, req.MaxPrice, req.MinStock, req.MaxStock)
+}
+
+func productSortValue(product models.Product, column string) interface{} {
+	switch column {
+	case "name":
+		return product.Name
+	case "price":
+		return product.Price
+	case "stock":
+		return product.Stock
+	default:
+		return product.Id
+	}
+}
+
+func newProductCursor(product models.Product, keys []sortKey, filters string) productCursor {
+	cursor := productCursor{Sort: orderClause(keys), Filters: filters}
+
+	for _, key := range keys {
+		cursor.Values = append(cursor.Values, productSortValue(product, key.Column))
+	}
+
+	return cursor
+}
+
+// keysetCondition selects the rows after values in the order given by keys.
+// Keys may mix directions, so it cannot be a single row comparison:
+// (a > ?) OR (a = ? AND b < ?) OR ...
+func keysetCondition(keys []sortKey, values []interface{}) (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+
+	for i, key := range keys {
+		var parts []string
+
+		for j := 0; j < i; j++ {
+			parts = append(parts, keys[j].Column+" = ?")
+			args = append(args, cursorValue(values[j]))
+		}
+
+		operator := " > ?"
+		if key.Desc {
+			operator = " < ?"
+		}
+
+		parts = append(parts, key.Column+operator)
+		args = append(args, cursorValue(values[i]))
+
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+
+	return "(" + strings.Join(clauses, " OR ") + ")", args
+}
+
+func cursorValue(value interface{}) interface{} {
+	if number, ok := value.(json.Number); ok {
+		if i, err := number.Int64(); err == nil {
+			return i
+		}
+	}
+
+	return value
+}
@@ -2,30 +2,97 @@ package services
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 
+	"common/pagetoken"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"product/pkg/db"
 	"product/pkg/models"
 	pb "product/pkg/pb"
 )
 
 type Server struct {
-	H db.Handler
+	H          db.Handler
+	PageTokens pagetoken.Signer
 	pb.UnimplementedProductServiceServer
 }
 
+func toProductData(product models.Product) *pb.ProductData {
+	data := &pb.ProductData{
+		Id:          product.Id,
+		Name:        product.Name,
+		Description: product.Description,
+		Sku:         product.Sku,
+		Stock:       product.Stock,
+		Price:       product.Price,
+	}
+
+	for _, category := range product.Categories {
+		data.CategoryIds = append(data.CategoryIds, category.Id)
+	}
+
+	for _, tag := range product.Tags {
+		data.Tags = append(data.Tags, tag.Name)
+	}
+
+	for _, variant := range product.Variants {
+		data.Variants = append(data.Variants, toVariantData(variant))
+	}
+
+	return data
+}
+
 func (s *Server) CreateProduct(ctx context.Context, req *pb.CreateProductRequest) (*pb.CreateProductResponse, error) {
 	var product models.Product
 
 	product.Name = req.Name
+	product.Description = req.Description
+	product.Sku = strings.TrimSpace(req.Sku)
 	product.Stock = req.Stock
 	product.Price = req.Price
 
-	if result := s.H.DB.Create(&product); result.Error != nil {
+	var res *pb.CreateProductResponse
+
+	err := s.H.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkSku(tx, product.Sku, 0, 0); err != nil {
+			return err
+		}
+
+		categories, err := findCategories(tx, req.CategoryIds)
+
+		if err != nil {
+			if errors.Is(err, errUnknownCategory) {
+				res = &pb.CreateProductResponse{
+					Status: http.StatusBadRequest,
+					Error:  err.Error(),
+				}
+			}
+			return err
+		}
+
+		tags, err := findOrCreateTags(tx, req.Tags)
+
+		if err != nil {
+			return err
+		}
+
+		product.Categories = categories
+		product.Tags = tags
+
+		return tx.Create(&product).Error
+	})
+
+	if err != nil {
+		if res != nil {
+			return res, nil
+		}
+
 		return &pb.CreateProductResponse{
-			Status: http.StatusConflict,
-			Error:  result.Error.Error(),
+			Status: errorStatus(err),
+			Error:  err.Error(),
 		}, nil
 	}
 
@@ -35,10 +102,34 @@ func (s *Server) CreateProduct(ctx context.Context, req *pb.CreateProductRequest
 	}, nil
 }
 
+// FindOne looks a product up by id or SKU. A variant SKU, or a variantId
+// next to the id, finds the parent product with the stock and price of the
+// variant.
 func (s *Server) FindOne(ctx context.Context, req *pb.FindOneRequest) (*pb.FindOneResponse, error) {
+	sku := strings.TrimSpace(req.Sku)
+
+	if req.Id == 0 && sku == "" {
+		return &pb.FindOneResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Id or SKU is required",
+		}, nil
+	}
+
 	var product models.Product
+	var variant models.Variant
+	var result *gorm.DB
+
+	if req.Id != 0 {
+		if result = s.H.DB.First(&product, req.Id); result.Error == nil && req.VariantId != 0 {
+			result = s.H.DB.Where("id = ? AND product_id = ?", req.VariantId, product.Id).First(&variant)
+		}
+	} else if result = s.H.DB.Where("sku = ?", sku).First(&product); errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		if result = s.H.DB.Where("sku = ?", sku).First(&variant); result.Error == nil {
+			result = s.H.DB.First(&product, variant.ProductId)
+		}
+	}
 
-	if result := s.H.DB.First(&product, req.Id); result.Error != nil {
+	if result.Error != nil {
 		return &pb.FindOneResponse{
 			Status: http.StatusNotFound,
 			Error:  result.Error.Error(),
@@ -48,57 +139,223 @@ func (s *Server) FindOne(ctx context.Context, req *pb.FindOneRequest) (*pb.FindO
 	data := &pb.FindOneData{
 		Id:    product.Id,
 		Name:  product.Name,
+		Sku:   product.Sku,
 		Stock: product.Stock,
 		Price: product.Price,
 	}
 
+	if variant.Id != 0 {
+		data.Sku = variant.Sku
+		data.Stock = variant.Stock
+		data.Price = variant.EffectivePrice(product)
+		data.VariantId = variant.Id
+	}
+
 	return &pb.FindOneResponse{
 		Status: http.StatusOK,
 		Data:   data,
 	}, nil
 }
 
+// The product row, and the variant row when variantId is set, stay locked
+// until the transaction commits, so concurrent orders cannot both pass the
+// stock check.
 func (s *Server) DecreaseStock(ctx context.Context, req *pb.DecreaseStockRequest) (*pb.DecreaseStockResponse, error) {
-	var product models.Product
+	quantity := req.Quantity
 
-	if result := s.H.DB.First(&product, req.Id); result.Error != nil {
-		return &pb.DecreaseStockResponse{
-			Status: http.StatusNotFound,
-			Error:  result.Error.Error(),
-		}, nil
+	// Callers that predate the quantity field always decreased by one.
+	if quantity == 0 {
+		quantity = 1
 	}
 
-	if product.Stock <= 0 {
+	if quantity < 0 {
 		return &pb.DecreaseStockResponse{
-			Status: http.StatusConflict,
-			Error:  "Stock too low",
+			Status: http.StatusBadRequest,
+			Error:  "Quantity must be positive",
 		}, nil
 	}
 
-	var log models.StockDecreaseLog
+	var res *pb.DecreaseStockResponse
+
+	err := s.H.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+
+		if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, req.Id); result.Error != nil {
+			res = &pb.DecreaseStockResponse{
+				Status: http.StatusNotFound,
+				Error:  result.Error.Error(),
+			}
+			return result.Error
+		}
+
+		var variant models.Variant
+
+		if req.VariantId != 0 {
+			if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND product_id = ?", req.VariantId, product.Id).First(&variant); result.Error != nil {
+				res = &pb.DecreaseStockResponse{
+					Status: http.StatusNotFound,
+					Error:  "Variant not found",
+				}
+				return result.Error
+			}
+		}
+
+		var log models.StockDecreaseLog
+
+		if result := tx.Where("order_id = ? AND product_refer = ? AND variant_id = ?", req.OrderId, product.Id, req.VariantId).First(&log); result.Error == nil {
+			res = &pb.DecreaseStockResponse{
+				Status: http.StatusConflict,
+				Error:  "Stock already decreased",
+			}
+			return errors.New(res.Error)
+		}
+
+		stock := product.Stock
+		if req.VariantId != 0 {
+			stock = variant.Stock
+		}
+
+		if stock < quantity {
+			res = &pb.DecreaseStockResponse{
+				Status: http.StatusConflict,
+				Error:  "Stock too low",
+			}
+			return errors.New(res.Error)
+		}
+
+		if req.VariantId != 0 {
+			if result := tx.Model(&variant).Update("stock", stock-quantity); result.Error != nil {
+				return result.Error
+			}
+		} else if result := tx.Model(&product).Update("stock", stock-quantity); result.Error != nil {
+			return result.Error
+		}
+
+		log.OrderId = req.OrderId
+		log.ProductRefer = product.Id
+		log.VariantId = req.VariantId
+		log.Quantity = quantity
+
+		return tx.Create(&log).Error
+	})
+
+	if err != nil {
+		if res != nil {
+			return res, nil
+		}
 
-	if result := s.H.DB.Where(&models.StockDecreaseLog{OrderId: req.OrderId}).First(&log); result.Error == nil {
 		return &pb.DecreaseStockResponse{
-			Status: http.StatusConflict,
-			Error:  "Stock already decreased",
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
 		}, nil
 	}
 
-	product.Stock = product.Stock - 1
+	return &pb.DecreaseStockResponse{
+		Status: http.StatusOK,
+	}, nil
+}
 
-	s.H.DB.Save(&product)
+// ReleaseStock puts back the stock reserved for an order. Releasing the same
+// order twice is a no-op.
+func (s *Server) ReleaseStock(ctx context.Context, req *pb.ReleaseStockRequest) (*pb.ReleaseStockResponse, error) {
+	var res *pb.ReleaseStockResponse
 
-	log.OrderId = req.OrderId
-	log.ProductRefer = product.Id
+	err := s.H.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var product models.Product
 
-	s.H.DB.Create(&log)
+		if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, req.Id); result.Error != nil {
+			res = &pb.ReleaseStockResponse{
+				Status: http.StatusNotFound,
+				Error:  result.Error.Error(),
+			}
+			return result.Error
+		}
 
-	return &pb.DecreaseStockResponse{
+		var log models.StockDecreaseLog
+
+		if result := tx.Where("order_id = ? AND product_refer = ? AND variant_id = ?", req.OrderId, product.Id, req.VariantId).First(&log); result.Error != nil {
+			res = &pb.ReleaseStockResponse{
+				Status: http.StatusNotFound,
+				Error:  "Stock decrease not found",
+			}
+			return result.Error
+		}
+
+		if log.Released {
+			return nil
+		}
+
+		quantity := log.Quantity
+
+		// Entries written before quantities were logged always decreased by one.
+		if quantity == 0 {
+			quantity = 1
+		}
+
+		if log.VariantId != 0 {
+			var variant models.Variant
+
+			if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, log.VariantId); result.Error != nil {
+				return result.Error
+			}
+
+			if result := tx.Model(&variant).Update("stock", variant.Stock+quantity); result.Error != nil {
+				return result.Error
+			}
+		} else if result := tx.Model(&product).Update("stock", product.Stock+quantity); result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Model(&log).Update("released", true).Error
+	})
+
+	if err != nil {
+		if res != nil {
+			return res, nil
+		}
+
+		return &pb.ReleaseStockResponse{
+			Status: http.StatusInternalServerError,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	return &pb.ReleaseStockResponse{
 		Status: http.StatusOK,
 	}, nil
 }
 
 func (s *Server) ListProducts(ctx context.Context, req *pb.ListProductsRequest) (*pb.ListProductsResponse, error) {
+	sort, err := parseSort(req.SortBy, req.SortOrder, productSortColumns)
+
+	if err != nil {
+		return &pb.ListProductsResponse{
+			Status: http.StatusBadRequest,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	if req.PageToken != "" && req.Page > 0 {
+		return &pb.ListProductsResponse{
+			Status: http.StatusBadRequest,
+			Error:  "page and pageToken cannot be combined",
+		}, nil
+	}
+
+	if req.MinPrice != nil && req.MaxPrice != nil && *req.MinPrice > *req.MaxPrice {
+		return &pb.ListProductsResponse{
+			Status: http.StatusBadRequest,
+			Error:  "minPrice must not be greater than maxPrice",
+		}, nil
+	}
+
+	if req.MinStock != nil && req.MaxStock != nil && *req.MinStock > *req.MaxStock {
+		return &pb.ListProductsResponse{
+			Status: http.StatusBadRequest,
+			Error:  "minStock must not be greater than maxStock",
+		}, nil
+	}
+
 	var products []models.Product
 	query := s.H.DB
 
@@ -106,87 +363,218 @@ func (s *Server) ListProducts(ctx context.Context, req *pb.ListProductsRequest)
 		query = query.Where("name LIKE ?", "%"+req.Filter+"%")
 	}
 
-	if req.SortBy != "" {
-		order := req.SortBy
-		if req.SortOrder != "" {
-			order = order + " " + strings.ToUpper(req.SortOrder)
+	if req.CategoryId != 0 {
+		categoryIds := []int64{req.CategoryId}
+
+		if req.IncludeSubcategories {
+			tree, err := categoryTree(s.H.DB, req.CategoryId)
+
+			if err != nil {
+				return &pb.ListProductsResponse{
+					Status: http.StatusInternalServerError,
+					Error:  err.Error(),
+				}, nil
+			}
+
+			categoryIds = tree
 		}
-		query = query.Order(order)
+
+		query = query.Where("id IN (SELECT product_id FROM product_categories WHERE category_id IN ?)", categoryIds)
+	}
+
+	if req.Tag != "" {
+		query = query.Where("id IN (SELECT pt.product_id FROM product_tags pt JOIN tags t ON t.id = pt.tag_id WHERE t.name = ?)",
+			strings.ToLower(strings.TrimSpace(req.Tag)))
+	}
+
+	if req.MinPrice != nil {
+		query = query.Where("price >= ?", *req.MinPrice)
 	}
 
+	if req.MaxPrice != nil {
+		query = query.Where("price <= ?", *req.MaxPrice)
+	}
+
+	if req.MinStock != nil {
+		query = query.Where("stock >= ?", *req.MinStock)
+	}
+
+	if req.MaxStock != nil {
+		query = query.Where("stock <= ?", *req.MaxStock)
+	}
+
+	// Keyset pages are read with a token, or by asking for a page size
+	// without a page number.
+	keyset := req.PageToken != "" || (req.Page == 0 && req.PageSize > 0)
+
 	var total int64
-	query.Model(&models.Product{}).Count(&total)
 
-	if req.Page > 0 && req.PageSize > 0 {
+	if !keyset || req.IncludeTotal {
+		query.Model(&models.Product{}).Count(&total)
+	}
+
+	if req.PageToken != "" {
+		var cursor productCursor
+
+		if err := s.PageTokens.Decode(req.PageToken, &cursor); err != nil {
+			return &pb.ListProductsResponse{
+				Status: http.StatusBadRequest,
+				Error:  err.Error(),
+			}, nil
+		}
+
+		if cursor.Sort != orderClause(sort) || len(cursor.Values) != len(sort) {
+			return &pb.ListProductsResponse{
+				Status: http.StatusBadRequest,
+				Error:  "Page token does not match the sort order",
+			}, nil
+		}
+
+		if cursor.Filters != productFilters(req) {
+			return &pb.ListProductsResponse{
+				Status: http.StatusBadRequest,
+				Error:  "Page token does not match the filters",
+			}, nil
+		}
+
+		condition, args := keysetCondition(sort, cursor.Values)
+		query = query.Where(condition, args...)
+	}
+
+	query = query.Order(orderClause(sort))
+
+	pageSize := int(req.PageSize)
+
+	if keyset {
+		if pageSize <= 0 {
+			pageSize = defaultPageSize
+		}
+
+		if pageSize > maxPageSize {
+			pageSize = maxPageSize
+		}
+
+		query = query.Limit(pageSize + 1)
+	} else if req.Page > 0 && req.PageSize > 0 {
 		offset := (req.Page - 1) * req.PageSize
 		query = query.Offset(int(offset)).Limit(int(req.PageSize))
 	}
 
-	if result := query.Find(&products); result.Error != nil {
+	if result := query.Preload("Categories").Preload("Tags").Preload("Variants").Find(&products); result.Error != nil {
 		return &pb.ListProductsResponse{
 			Status: http.StatusInternalServerError,
 			Error:  result.Error.Error(),
 		}, nil
 	}
 
+	var nextPageToken string
+
+	if keyset && len(products) > pageSize {
+		products = products[:pageSize]
+
+		token, err := s.PageTokens.Encode(newProductCursor(products[pageSize-1], sort, productFilters(req)))
+
+		if err != nil {
+			return &pb.ListProductsResponse{
+				Status: http.StatusInternalServerError,
+				Error:  err.Error(),
+			}, nil
+		}
+
+		nextPageToken = token
+	}
+
 	var productData []*pb.ProductData
 	for _, product := range products {
-		productData = append(productData, &pb.ProductData{
-			Id:    product.Id,
-			Name:  product.Name,
-			Stock: product.Stock,
-			Price: product.Price,
-		})
+		productData = append(productData, toProductData(product))
 	}
 
 	return &pb.ListProductsResponse{
-		Status:   http.StatusOK,
-		Products: productData,
-		Total:    total,
+		Status:        http.StatusOK,
+		Products:      productData,
+		Total:         total,
+		NextPageToken: nextPageToken,
 	}, nil
 }
 
 func (s *Server) ReadProduct(ctx context.Context, req *pb.ReadProductRequest) (*pb.ReadProductResponse, error) {
 	var product models.Product
 
-	if result := s.H.DB.First(&product, req.Id); result.Error != nil {
+	if result := s.H.DB.Preload("Categories").Preload("Tags").Preload("Variants").First(&product, req.Id); result.Error != nil {
 		return &pb.ReadProductResponse{
 			Status: http.StatusNotFound,
 			Error:  result.Error.Error(),
 		}, nil
 	}
 
-	data := &pb.ProductData{
-		Id:    product.Id,
-		Name:  product.Name,
-		Stock: product.Stock,
-		Price: product.Price,
-	}
-
 	return &pb.ReadProductResponse{
 		Status:  http.StatusOK,
-		Product: data,
+		Product: toProductData(product),
 	}, nil
 }
 
+// UpdateProduct replaces the product, including its categories and tags.
 func (s *Server) UpdateProduct(ctx context.Context, req *pb.UpdateProductRequest) (*pb.UpdateProductResponse, error) {
-	var product models.Product
+	var res *pb.UpdateProductResponse
 
-	if result := s.H.DB.First(&product, req.Id); result.Error != nil {
-		return &pb.UpdateProductResponse{
-			Status: http.StatusNotFound,
-			Error:  result.Error.Error(),
-		}, nil
-	}
+	err := s.H.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var product models.Product
 
-	product.Name = req.Name
-	product.Stock = req.Stock
-	product.Price = req.Price
+		if result := tx.First(&product, req.Id); result.Error != nil {
+			res = &pb.UpdateProductResponse{
+				Status: http.StatusNotFound,
+				Error:  result.Error.Error(),
+			}
+			return result.Error
+		}
+
+		if err := checkSku(tx, strings.TrimSpace(req.Sku), product.Id, 0); err != nil {
+			return err
+		}
+
+		categories, err := findCategories(tx, req.CategoryIds)
+
+		if err != nil {
+			if errors.Is(err, errUnknownCategory) {
+				res = &pb.UpdateProductResponse{
+					Status: http.StatusBadRequest,
+					Error:  err.Error(),
+				}
+			}
+			return err
+		}
+
+		tags, err := findOrCreateTags(tx, req.Tags)
+
+		if err != nil {
+			return err
+		}
+
+		product.Name = req.Name
+		product.Description = req.Description
+		product.Sku = strings.TrimSpace(req.Sku)
+		product.Stock = req.Stock
+		product.Price = req.Price
+
+		if result := tx.Omit(clause.Associations).Save(&product); result.Error != nil {
+			return result.Error
+		}
+
+		if err := tx.Model(&product).Association("Categories").Replace(categories); err != nil {
+			return err
+		}
+
+		return tx.Model(&product).Association("Tags").Replace(tags)
+	})
+
+	if err != nil {
+		if res != nil {
+			return res, nil
+		}
 
-	if result := s.H.DB.Save(&product); result.Error != nil {
 		return &pb.UpdateProductResponse{
-			Status: http.StatusInternalServerError,
-			Error:  result.Error.Error(),
+			Status: errorStatus(err),
+			Error:  err.Error(),
 		}, nil
 	}
 
@@ -196,7 +584,9 @@ func (s *Server) UpdateProduct(ctx context.Context, req *pb.UpdateProductRequest
 }
 
 func (s *Server) DeleteProduct(ctx context.Context, req *pb.DeleteProductRequest) (*pb.DeleteProductResponse, error) {
-	if result := s.H.DB.Delete(&models.Product{}, req.Id); result.Error != nil {
+	// Selecting the associations clears the join tables and variants along
+	// with the row.
+	if result := s.H.DB.Select("Categories", "Tags", "Variants").Delete(&models.Product{Id: req.Id}); result.Error != nil {
 		return &pb.DeleteProductResponse{
 			Status: http.StatusInternalServerError,
 			Error:  result.Error.Error(),
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"product/pkg/models"
+	pb "product/pkg/pb"
+)
+
+const (
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 100
+)
+
+type searchRow struct {
+	models.Product
+	Rank    float64
+	Snippet string
+}
+
+// prefixQuery turns free text into a tsquery that requires every word as a
+// prefix. Anything but letters and digits is dropped so user input cannot
+// inject tsquery operators.
+func prefixQuery(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+
+	return strings.Join(words, " & ")
+}
+
+func (s *Server) SearchProducts(ctx context.Context, req *pb.SearchProductsRequest) (*pb.SearchProductsResponse, error) {
+	tsquery := prefixQuery(req.Query)
+
+	if tsquery == "" {
+		return &pb.SearchProductsResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Query is required",
+		}, nil
+	}
+
+	pageSize := req.PageSize
+
+	if pageSize <= 0 {
+		pageSize = defaultSearchPageSize
+	}
+
+	if pageSize > maxSearchPageSize {
+		pageSize = maxSearchPageSize
+	}
+
+	page := req.Page
+
+	if page <= 0 {
+		page = 1
+	}
+
+	var total int64
+
+	if result := s.H.DB.Model(&models.Product{}).Where("search_vector @@ to_tsquery('english', ?)", tsquery).Count(&total); result.Error != nil {
+		return &pb.SearchProductsResponse{
+			Status: http.StatusInternalServerError,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	var rows []searchRow
+
+	result := s.H.DB.Table("products, to_tsquery('english', ?) AS query", tsquery).
+		Select(`products.*,
+			ts_rank(products.search_vector, query) AS rank,
+			ts_headline('english', CASE WHEN products.description = '' THEN products.name ELSE products.description END, query,
+				'MaxWords=35, MinWords=15, MaxFragments=2') AS snippet`).
+		Where("products.search_vector @@ query").
+		Order("rank DESC, products.id").
+		Offset(int((page - 1) * pageSize)).
+		Limit(int(pageSize)).
+		Scan(&rows)
+
+	if result.Error != nil {
+		return &pb.SearchProductsResponse{
+			Status: http.StatusInternalServerError,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	ids := make([]int64, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.Id)
+	}
+
+	// Categories and tags are loaded separately since Scan skips associations.
+	var products []models.Product
+	byId := make(map[int64]models.Product)
+
+	if len(ids) > 0 {
+		if result := s.H.DB.Preload("Categories").Preload("Tags").Preload("Variants").Find(&products, ids); result.Error != nil {
+			return &pb.SearchProductsResponse{
+				Status: http.StatusInternalServerError,
+				Error:  result.Error.Error(),
+			}, nil
+		}
+	}
+
+	for _, product := range products {
+		byId[product.Id] = product
+	}
+
+	var results []*pb.SearchProductsResult
+	for _, row := range rows {
+		product, ok := byId[row.Id]
+
+		if !ok {
+			product = row.Product
+		}
+
+		results = append(results, &pb.SearchProductsResult{
+			Product: toProductData(product),
+			Rank:    row.Rank,
+			Snippet: row.Snippet,
+		})
+	}
+
+	return &pb.SearchProductsResponse{
+		Status:  http.StatusOK,
+		Results: results,
+		Total:   total,
+	}, nil
+}
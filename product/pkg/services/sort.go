@@ -0,0 +1,104 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// productSortColumns whitelists the fields ListProducts can sort by.
+var productSortColumns = map[string]string{
+	"id":    "id",
+	"name":  "name",
+	"price": "price",
+	"stock": "stock",
+}
+
+type sortKey struct {
+	Column string
+	Desc   bool
+}
+
+// parseSort reads a sort spec such as "price desc, name". Keys without a
+// direction use defaultOrder, which is what the old single-field sortOrder
+// parameter still maps to. id is appended as a tie-breaker so that pages
+// stay stable; on its own it follows defaultOrder too.
+func parseSort(spec string, defaultOrder string, columns map[string]string) ([]sortKey, error) {
+	defaultDesc, err := parseDirection(defaultOrder)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []sortKey
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(spec, ",") {
+		fields := strings.Fields(part)
+
+		if len(fields) == 0 {
+			if strings.TrimSpace(spec) == "" {
+				break
+			}
+
+			return nil, fmt.Errorf("Empty sort key in %q", spec)
+		}
+
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("Invalid sort key %q", strings.TrimSpace(part))
+		}
+
+		column, ok := columns[fields[0]]
+
+		if !ok {
+			return nil, fmt.Errorf("Cannot sort by %q", fields[0])
+		}
+
+		if seen[column] {
+			return nil, fmt.Errorf("Duplicate sort key %q", fields[0])
+		}
+
+		seen[column] = true
+		key := sortKey{Column: column, Desc: defaultDesc}
+
+		if len(fields) == 2 {
+			if key.Desc, err = parseDirection(fields[1]); err != nil {
+				return nil, err
+			}
+		}
+
+		keys = append(keys, key)
+	}
+
+	if !seen["id"] {
+		keys = append(keys, sortKey{Column: "id", Desc: len(keys) == 0 && defaultDesc})
+	}
+
+	return keys, nil
+}
+
+func parseDirection(direction string) (bool, error) {
+	switch strings.ToLower(direction) {
+	case "", "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	default:
+		return false, fmt.Errorf("Sort order must be asc or desc, not %q", direction)
+	}
+}
+
+// orderClause renders keys for gorm's Order. Columns only ever come from the
+// whitelist, never from the request.
+func orderClause(keys []sortKey) string {
+	parts := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		if key.Desc {
+			parts = append(parts, key.Column+" DESC")
+		} else {
+			parts = append(parts, key.Column+" ASC")
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
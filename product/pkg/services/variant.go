@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+	"product/pkg/models"
+	pb "product/pkg/pb"
+)
+
+var errSkuTaken = errors.New("SKU already in use")
+
+// uniqueViolation is the Postgres error code for a duplicate key.
+const uniqueViolation = "23505"
+
+// errorStatus answers a failed save with 409 when a SKU or another unique
+// value is already taken, and with 500 for anything else.
+func errorStatus(err error) int64 {
+	var pgErr *pgconn.PgError
+
+	if errors.Is(err, errSkuTaken) || errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return http.StatusConflict
+	}
+
+	return http.StatusInternalServerError
+}
+
+func toVariantData(variant models.Variant) *pb.VariantData {
+	return &pb.VariantData{
+		Id:        variant.Id,
+		ProductId: variant.ProductId,
+		Sku:       variant.Sku,
+		Size:      variant.Size,
+		Color:     variant.Color,
+		Price:     variant.Price,
+		Stock:     variant.Stock,
+	}
+}
+
+// checkSku makes sure a SKU names a single product or variant. productId and
+// variantId exclude the row being saved; the unique indexes only cover each
+// table on its own.
+func checkSku(tx *gorm.DB, sku string, productId int64, variantId int64) error {
+	if sku == "" {
+		return nil
+	}
+
+	var count int64
+
+	if result := tx.Model(&models.Product{}).Where("sku = ? AND id <> ?", sku, productId).Count(&count); result.Error != nil {
+		return result.Error
+	}
+
+	if count > 0 {
+		return errSkuTaken
+	}
+
+	if result := tx.Model(&models.Variant{}).Where("sku = ? AND id <> ?", sku, variantId).Count(&count); result.Error != nil {
+		return result.Error
+	}
+
+	if count > 0 {
+		return errSkuTaken
+	}
+
+	return nil
+}
+
+func (s *Server) CreateVariant(ctx context.Context, req *pb.CreateVariantRequest) (*pb.CreateVariantResponse, error) {
+	variant := models.Variant{
+		ProductId: req.ProductId,
+		Sku:       strings.TrimSpace(req.Sku),
+		Size:      req.Size,
+		Color:     req.Color,
+		Price:     req.Price,
+		Stock:     req.Stock,
+	}
+
+	if variant.Sku == "" {
+		return &pb.CreateVariantResponse{
+			Status: http.StatusBadRequest,
+			Error:  "SKU is required",
+		}, nil
+	}
+
+	var res *pb.CreateVariantResponse
+
+	err := s.H.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if result := tx.First(&models.Product{}, req.ProductId); result.Error != nil {
+			res = &pb.CreateVariantResponse{
+				Status: http.StatusNotFound,
+				Error:  result.Error.Error(),
+			}
+			return result.Error
+		}
+
+		if err := checkSku(tx, variant.Sku, 0, 0); err != nil {
+			return err
+		}
+
+		return tx.Create(&variant).Error
+	})
+
+	if err != nil {
+		if res != nil {
+			return res, nil
+		}
+
+		return &pb.CreateVariantResponse{
+			Status: errorStatus(err),
+			Error:  err.Error(),
+		}, nil
+	}
+
+	return &pb.CreateVariantResponse{
+		Status: http.StatusCreated,
+		Id:     variant.Id,
+	}, nil
+}
+
+func (s *Server) UpdateVariant(ctx context.Context, req *pb.UpdateVariantRequest) (*pb.UpdateVariantResponse, error) {
+	sku := strings.TrimSpace(req.Sku)
+
+	if sku == "" {
+		return &pb.UpdateVariantResponse{
+			Status: http.StatusBadRequest,
+			Error:  "SKU is required",
+		}, nil
+	}
+
+	var res *pb.UpdateVariantResponse
+
+	err := s.H.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var variant models.Variant
+
+		if result := tx.Where("id = ? AND product_id = ?", req.Id, req.ProductId).First(&variant); result.Error != nil {
+			res = &pb.UpdateVariantResponse{
+				Status: http.StatusNotFound,
+				Error:  "Variant not found",
+			}
+			return result.Error
+		}
+
+		if err := checkSku(tx, sku, 0, variant.Id); err != nil {
+			return err
+		}
+
+		variant.Sku = sku
+		variant.Size = req.Size
+		variant.Color = req.Color
+		variant.Price = req.Price
+		variant.Stock = req.Stock
+
+		return tx.Save(&variant).Error
+	})
+
+	if err != nil {
+		if res != nil {
+			return res, nil
+		}
+
+		return &pb.UpdateVariantResponse{
+			Status: errorStatus(err),
+			Error:  err.Error(),
+		}, nil
+	}
+
+	return &pb.UpdateVariantResponse{
+		Status: http.StatusOK,
+	}, nil
+}
+
+func (s *Server) DeleteVariant(ctx context.Context, req *pb.DeleteVariantRequest) (*pb.DeleteVariantResponse, error) {
+	result := s.H.DB.Where("id = ? AND product_id = ?", req.Id, req.ProductId).Delete(&models.Variant{})
+
+	if result.Error != nil {
+		return &pb.DeleteVariantResponse{
+			Status: http.StatusInternalServerError,
+			Error:  result.Error.Error(),
+		}, nil
+	}
+
+	if result.RowsAffected == 0 {
+		return &pb.DeleteVariantResponse{
+			Status: http.StatusNotFound,
+			Error:  "Variant not found",
+		}, nil
+	}
+
+	return &pb.DeleteVariantResponse{
+		Status: http.StatusOK,
+	}, nil
+}